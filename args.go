@@ -0,0 +1,63 @@
+package sflag
+
+import (
+	"flag"
+	"strings"
+)
+
+// NormalizeArgs returns a copy of args, the command line arguments to
+// be parsed by fs, in which the names of the flags added with the
+// WithCaseInsensitiveNames option are rewritten to their canonical
+// case. The flag package being case sensitive, the returned arguments
+// must be given to fs.Parse instead of args. Both the -name value and
+// the -name=value forms, with one or two dashes, are handled. Like
+// fs.Parse, NormalizeArgs stops at the first non-flag argument or
+// after the "--" terminator.
+func NormalizeArgs(fs *flag.FlagSet, args []string) []string {
+	var lowerNames map[string]string
+	readFlagSetInfo(fs, func(info *flagSetInfo) {
+		if info != nil {
+			lowerNames = make(map[string]string, len(info.lowerNames))
+			for lower, name := range info.lowerNames {
+				lowerNames[lower] = name
+			}
+		}
+	})
+	norm := make([]string, len(args))
+	copy(norm, args)
+	for i := 0; i < len(norm); i++ {
+		arg := norm[i]
+		if len(arg) < 2 || arg[0] != '-' || arg == "--" {
+			break
+		}
+		dashes := "-"
+		if arg[1] == '-' {
+			dashes = "--"
+		}
+		name, value, hasValue := strings.Cut(arg[len(dashes):], "=")
+		if canonical, ok := lowerNames[strings.ToLower(name)]; ok {
+			name = canonical
+			if hasValue {
+				norm[i] = dashes + name + "=" + value
+			} else {
+				norm[i] = dashes + name
+			}
+		}
+		if fl := fs.Lookup(name); fl != nil && !hasValue && !isBoolFlag(fl) {
+			// Skip the value of the flag
+			i++
+		}
+	}
+	return norm
+}
+
+func isBoolFlag(fl *flag.Flag) bool {
+	return isBoolValue(fl.Value)
+}
+
+// isBoolValue reports whether v is the value of a boolean flag, i.e.
+// a flag that doesn't require a value.
+func isBoolValue(v flag.Value) bool {
+	bv, ok := v.(interface{ IsBoolFlag() bool })
+	return ok && bv.IsBoolFlag()
+}
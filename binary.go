@@ -0,0 +1,97 @@
+package sflag
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+)
+
+// stringEncoding is a binary-to-text encoding.
+type stringEncoding interface {
+	EncodeToString(b []byte) string
+	DecodeString(s string) ([]byte, error)
+}
+
+// hexEncoding is the hexadecimal stringEncoding.
+type hexEncoding struct{}
+
+func (hexEncoding) EncodeToString(b []byte) string {
+	return hex.EncodeToString(b)
+}
+
+func (hexEncoding) DecodeString(s string) ([]byte, error) {
+	return hex.DecodeString(s)
+}
+
+// binaryEncodings maps the values of the binary marker to the encoding
+// they stand for.
+var binaryEncodings = map[string]stringEncoding{
+	"base64":    base64.StdEncoding,
+	"base64url": base64.URLEncoding,
+	"hex":       hexEncoding{},
+}
+
+var (
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+	binaryMarshalerType   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+)
+
+// binaryValue is a flag.Value decoding its value using enc before
+// parsing it using the UnmarshalBinary method of a value of type typ.
+// The value is formatted using its MarshalBinary method if any.
+type binaryValue struct {
+	v   reflect.Value
+	enc stringEncoding
+}
+
+func newBinaryValue(typ reflect.Type, encoding string) (*binaryValue, error) {
+	enc := binaryEncodings[encoding]
+	if enc == nil {
+		return nil, fmt.Errorf("unknown binary encoding %q", encoding)
+	}
+	if !reflect.PointerTo(typ).Implements(binaryUnmarshalerType) {
+		return nil, fmt.Errorf("binary flags require a type implementing %q", binaryUnmarshalerType)
+	}
+	return &binaryValue{v: reflect.New(typ).Elem(), enc: enc}, nil
+}
+
+func (v *binaryValue) Set(s string) error {
+	b, err := v.enc.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	pv := reflect.New(v.v.Type())
+	if err := pv.Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(b); err != nil {
+		return err
+	}
+	v.v = pv.Elem()
+	return nil
+}
+
+func (v *binaryValue) String() string {
+	if v == nil || !v.v.IsValid() || v.v.IsZero() {
+		return ""
+	}
+	return formatBinary(v.v, v.enc)
+}
+
+func (v *binaryValue) Get() any {
+	return v.v.Interface()
+}
+
+// formatBinary formats v using its MarshalBinary method, if any, and
+// enc. It returns the empty string if v can't be marshaled.
+func formatBinary(v reflect.Value, enc stringEncoding) string {
+	pv := reflect.New(v.Type())
+	pv.Elem().Set(v)
+	if !pv.Type().Implements(binaryMarshalerType) {
+		return ""
+	}
+	b, err := pv.Interface().(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return ""
+	}
+	return enc.EncodeToString(b)
+}
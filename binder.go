@@ -0,0 +1,261 @@
+package sflag
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+)
+
+// Binder binds the fields of one or more structs to the flags of a
+// FlagSet, e.g. the configuration structs of different packages. The
+// tagged fields of the structs are computed once by Add and reused by
+// every call to Apply, which makes a Binder suitable for structs set
+// repeatedly.
+type Binder struct {
+	o        *options
+	bindings []*binding
+	// owners maps the names of the flags to the binding of the
+	// struct defining them
+	owners      map[string]*binding
+	validators  []validator
+	normalizers []normalizer
+	computed    []*computedFlag
+}
+
+// binding holds a struct bound by a Binder and its tagged fields.
+type binding struct {
+	v      reflect.Value
+	fields []*taggedField
+	// names maps the names of the flags to their field
+	names map[string]*taggedField
+	// args is the field receiving the positional arguments, if any
+	args *taggedField
+}
+
+// validator is a validation function registered for a field.
+type validator struct {
+	b  *binding
+	f  *taggedField
+	fn func(v any) error
+}
+
+// normalizer is a normalization function registered for a field.
+type normalizer struct {
+	b  *binding
+	f  *taggedField
+	fn func(s string) string
+}
+
+// computedFlag is a flag registered with AddComputed.
+type computedFlag struct {
+	name  string
+	deflt string
+	help  string
+	fn    func(v string) error
+}
+
+// NewBinder returns a Binder without struct. opts must be the options
+// used to add the flags to the FlagSets given to Apply.
+func NewBinder(opts ...Option) *Binder {
+	return &Binder{
+		o:      newOptions(opts),
+		owners: make(map[string]*binding),
+	}
+}
+
+// Add binds the struct pointed to by s. The flags derived from its
+// fields must not conflict with the flags of the structs already
+// bound.
+func (b *Binder) Add(s any) error {
+	v := reflect.ValueOf(s)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return errors.New("not a pointer to a struct")
+	}
+	bd := &binding{
+		v:     v.Elem(),
+		names: make(map[string]*taggedField),
+	}
+	err := visitFields(bd.v.Type(), b.o, func(f *taggedField) error {
+		for _, name := range f.names() {
+			if _, ok := bd.names[name]; ok {
+				return fieldError(DuplicateFlag, f.path, name, "duplicate flag %q", name)
+			}
+			if other := b.owners[name]; other != nil {
+				return fieldError(DuplicateFlag, f.path, name, "flag %q of struct %q already defined by struct %q", name, bd.v.Type(), other.v.Type())
+			}
+			if b.lookupComputed(name) != nil {
+				return fieldError(DuplicateFlag, f.path, name, "flag %q of struct %q already defined as a computed flag", name, bd.v.Type())
+			}
+			bd.names[name] = f
+		}
+		bd.fields = append(bd.fields, f)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if bd.args, err = argsField(bd.v.Type(), b.o); err != nil {
+		return err
+	}
+	for name := range bd.names {
+		b.owners[name] = bd
+	}
+	b.bindings = append(b.bindings, bd)
+	return nil
+}
+
+// AddComputed registers a string flag which doesn't correspond to a
+// field, e.g. a flag -addr host:port setting both the Host and Port
+// fields of a bound struct. After setting the fields, Apply calls fn
+// with the value of the flag if the flag was given on the command line
+// or has a non-empty default value, fn setting the fields itself. The
+// name of the flag must not conflict with the flags of the bound
+// structs.
+func (b *Binder) AddComputed(name string, deflt string, help string, fn func(v string) error) error {
+	if b.owners[name] != nil || b.lookupComputed(name) != nil {
+		return fmt.Errorf("duplicate flag %q", name)
+	}
+	b.computed = append(b.computed, &computedFlag{name, deflt, help, fn})
+	return nil
+}
+
+// AddStructMap registers fn to parse the values of the entries of the
+// map flag name, e.g. a map[string]ServerSpec field whose entries are
+// given as -server name=host:port:weight. The value returned by fn
+// must be assignable to the values of the map. It lets the map fields
+// hold values, e.g. structs, which can't be parsed by the package. It
+// must be called before AddFlags.
+func (b *Binder) AddStructMap(name string, fn func(s string) (any, error)) error {
+	bd := b.owners[name]
+	if bd == nil {
+		return fmt.Errorf("unknown flag %q", name)
+	}
+	f := bd.names[name]
+	typ := f.Type
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() != reflect.Map {
+		return fmt.Errorf("flag %q is not a map flag", name)
+	}
+	if b.o.mapParsers == nil {
+		b.o.mapParsers = make(map[string]func(s string) (any, error))
+	}
+	b.o.mapParsers[f.name] = fn
+	return nil
+}
+
+// lookupComputed returns the computed flag name, or nil if there's
+// none.
+func (b *Binder) lookupComputed(name string) *computedFlag {
+	for _, c := range b.computed {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// AddFlags adds to fs the flags derived from the fields of the bound
+// structs, like AddFlagsWith does, and the computed flags.
+func (b *Binder) AddFlags(fs *flag.FlagSet) error {
+	for _, bd := range b.bindings {
+		for _, f := range bd.fields {
+			fv, _ := bd.v.FieldByIndexErr(f.index)
+			if err := addFlag(fs, f, fv, b.o); err != nil {
+				return err
+			}
+		}
+	}
+	for _, c := range b.computed {
+		fs.String(c.name, c.deflt, c.help)
+	}
+	setUsage(fs, b.o)
+	return nil
+}
+
+// AddValidator registers fn to validate the field corresponding to the
+// flag name, which can also be one of the aliases of the flag. After
+// setting the fields, Apply calls fn with the value of the field. The
+// validators of a field are called in the order of their
+// registration.
+func (b *Binder) AddValidator(name string, fn func(v any) error) error {
+	bd := b.owners[name]
+	if bd == nil {
+		return fmt.Errorf("unknown flag %q", name)
+	}
+	if !bd.names[name].IsExported() {
+		return fmt.Errorf("flag %q bound to an unexported field", name)
+	}
+	b.validators = append(b.validators, validator{bd, bd.names[name], fn})
+	return nil
+}
+
+// AddNormalizer registers fn to normalize the string field
+// corresponding to the flag name, which can also be one of the aliases
+// of the flag, e.g. strings.ToLower or strings.TrimSpace. After setting
+// the fields, Apply replaces the value of the field by the value
+// returned by fn. The normalizers of a field are called in the order
+// of their registration.
+func (b *Binder) AddNormalizer(name string, fn func(s string) string) error {
+	bd := b.owners[name]
+	if bd == nil {
+		return fmt.Errorf("unknown flag %q", name)
+	}
+	f := bd.names[name]
+	if !f.IsExported() {
+		return fmt.Errorf("flag %q bound to an unexported field", name)
+	}
+	if f.Type.Kind() != reflect.String {
+		return fmt.Errorf("flag %q is not a string flag", name)
+	}
+	b.normalizers = append(b.normalizers, normalizer{bd, f, fn})
+	return nil
+}
+
+// Apply is like SetFromFlagsWith for each bound struct but uses the
+// fields computed by Add. Once the fields are set, the normalizers
+// registered with AddNormalizer are applied, then the functions of the
+// computed flags registered with AddComputed are called in the order
+// of their registration, and finally the validators registered with
+// AddValidator are run and their errors are joined.
+func (b *Binder) Apply(fs *flag.FlagSet) error {
+	if !fs.Parsed() {
+		return errors.New("flag not parsed")
+	}
+	for _, bd := range b.bindings {
+		if err := setFields(context.Background(), bd.v, fs, bd.names, b.o); err != nil {
+			return err
+		}
+		assignArgs(bd.v, fs, bd.args)
+	}
+	for _, n := range b.normalizers {
+		fv := fieldByIndex(n.b.v, n.f.index)
+		fv.SetString(n.fn(fv.String()))
+	}
+	explicit := make(map[string]bool)
+	fs.Visit(func(fl *flag.Flag) {
+		explicit[fl.Name] = true
+	})
+	for _, c := range b.computed {
+		fl := fs.Lookup(c.name)
+		if fl == nil {
+			return fmt.Errorf("computed flag %q not defined", c.name)
+		}
+		if !explicit[c.name] && fl.Value.String() == "" {
+			continue
+		}
+		if err := c.fn(fl.Value.String()); err != nil {
+			return fmt.Errorf("flag %q: %w", c.name, err)
+		}
+	}
+	var errs []error
+	for _, val := range b.validators {
+		if err := val.fn(fieldByIndex(val.b.v, val.f.index).Interface()); err != nil {
+			errs = append(errs, fmt.Errorf("flag %q: %w", val.f.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
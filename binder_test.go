@@ -0,0 +1,120 @@
+package sflag
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"testing"
+	"time"
+)
+
+type benchConfig struct {
+	Host    string        `flag:"host,localhost,host to connect to"`
+	Port    int           `flag:"port,8080,port to connect to|min=1|max=65535"`
+	Timeout time.Duration `flag:"timeout,5s,connection timeout"`
+	Tags    []string      `flag:"tag,,tags|sep=,"`
+	Verbose int           `flag:"v,,verbosity|count"`
+	Server  struct {
+		Name string `flag:"name,srv,server name"`
+		TLS  bool   `flag:"tls,,enable TLS|negatable"`
+	}
+}
+
+var benchArgs = []string{"-host", "example.com", "-port", "443", "-tag", "a,b", "-v", "-v", "-tls"}
+
+// newBenchFlagSet returns a FlagSet holding the flags of c.
+func newBenchFlagSet(b *testing.B, c *benchConfig) *flag.FlagSet {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if err := AddFlagsWith(fs, c); err != nil {
+		b.Fatal(err)
+	}
+	return fs
+}
+
+func BenchmarkApply(b *testing.B) {
+	b.Run("Binder", func(b *testing.B) {
+		var c benchConfig
+		bd := NewBinder()
+		if err := bd.Add(&c); err != nil {
+			b.Fatal(err)
+		}
+		fs := newBenchFlagSet(b, &c)
+		if err := fs.Parse(benchArgs); err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := bd.Apply(fs); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("SetFromFlags", func(b *testing.B) {
+		var c benchConfig
+		fs := newBenchFlagSet(b, &c)
+		if err := fs.Parse(benchArgs); err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := SetFromFlagsErr(&c, fs); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestBinder(t *testing.T) {
+	var a struct {
+		Host string `flag:"host,localhost,host"`
+		Port int    `flag:"port,80,port"`
+	}
+	var b struct {
+		Debug bool `flag:"debug,,debug"`
+	}
+	bd := NewBinder()
+	if err := bd.Add(&a); err != nil {
+		t.Fatal(err)
+	}
+	if err := bd.Add(&b); err != nil {
+		t.Fatal(err)
+	}
+	var dup struct {
+		Port int `flag:"port,,port"`
+	}
+	if err := bd.Add(&dup); err == nil {
+		t.Error("got no error for a flag defined by two structs")
+	}
+	if err := bd.AddValidator("port", func(v any) error {
+		if v.(int) == 0 {
+			return errors.New("zero port")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := bd.AddFlags(fs); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse([]string{"-port", "8080", "-debug"}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := bd.Apply(fs); err != nil {
+			t.Fatal(err)
+		}
+		if a.Host != "localhost" || a.Port != 8080 || !b.Debug {
+			t.Errorf("got %+v %+v", a, b)
+		}
+	}
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	bd.AddFlags(fs)
+	fs.Parse([]string{"-port", "0"})
+	if err := bd.Apply(fs); err == nil {
+		t.Error("got no error from the validator")
+	}
+}
@@ -0,0 +1,135 @@
+package sflag
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// byteUnits are the units recognized by byteSizeValue, ordered by
+// decreasing size.
+var byteUnits = []struct {
+	name string
+	size uint64
+}{
+	{"EiB", 1 << 60},
+	{"EB", 1e18},
+	{"PiB", 1 << 50},
+	{"PB", 1e15},
+	{"TiB", 1 << 40},
+	{"TB", 1e12},
+	{"GiB", 1 << 30},
+	{"GB", 1e9},
+	{"MiB", 1 << 20},
+	{"MB", 1e6},
+	{"KiB", 1 << 10},
+	{"KB", 1e3},
+	{"B", 1},
+}
+
+// parseByteSize parses a human readable size made of a number
+// followed by an optional unit, e.g. "10MB" or "1.5 GiB". SI units
+// (KB, MB, ...) are powers of 1000 and IEC units (KiB, MiB, ...) are
+// powers of 1024. Units are case insensitive but single letter units,
+// e.g. "M", are rejected as ambiguous.
+func parseByteSize(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	i := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	num, unit := s, ""
+	if i >= 0 {
+		num, unit = s[:i], strings.TrimSpace(s[i:])
+	}
+	if num == "" {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	size := uint64(1)
+	if unit != "" {
+		found := false
+		for _, u := range byteUnits {
+			if strings.EqualFold(unit, u.name) {
+				size, found = u.size, true
+				break
+			}
+		}
+		if !found {
+			return 0, fmt.Errorf("invalid unit %q in size %q", unit, s)
+		}
+	}
+	if n, err := strconv.ParseUint(num, 10, 64); err == nil {
+		if n > math.MaxUint64/size {
+			return 0, fmt.Errorf("size %q out of range", s)
+		}
+		return n * size, nil
+	}
+	f, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	f *= float64(size)
+	if f != math.Trunc(f) {
+		return 0, fmt.Errorf("size %q is not a whole number of bytes", s)
+	}
+	if f >= math.MaxUint64 {
+		return 0, fmt.Errorf("size %q out of range", s)
+	}
+	return uint64(f), nil
+}
+
+// formatByteSize formats n using the largest unit dividing it.
+func formatByteSize(n uint64) string {
+	for _, u := range byteUnits {
+		if n >= u.size && n%u.size == 0 {
+			return strconv.FormatUint(n/u.size, 10) + u.name
+		}
+	}
+	return "0B"
+}
+
+// byteSizeValue is a flag.Value parsing a human readable size using
+// parseByteSize. typ is the integer type of the value returned by
+// Get.
+type byteSizeValue struct {
+	n   uint64
+	typ reflect.Type
+}
+
+func newByteSizeValue(typ reflect.Type) (*byteSizeValue, error) {
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &byteSizeValue{typ: typ}, nil
+	}
+	return nil, fmt.Errorf("byte size flags require an integer type")
+}
+
+func (v *byteSizeValue) Set(s string) error {
+	n, err := parseByteSize(s)
+	if err != nil {
+		return err
+	}
+	z := reflect.New(v.typ).Elem()
+	switch v.typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n > math.MaxInt64 || z.OverflowInt(int64(n)) {
+			return fmt.Errorf("size %q out of range for %s", s, v.typ)
+		}
+	default:
+		if z.OverflowUint(n) {
+			return fmt.Errorf("size %q out of range for %s", s, v.typ)
+		}
+	}
+	v.n = n
+	return nil
+}
+
+func (v *byteSizeValue) String() string {
+	return formatByteSize(v.n)
+}
+
+func (v *byteSizeValue) Get() any {
+	return reflect.ValueOf(v.n).Convert(v.typ).Interface()
+}
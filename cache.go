@@ -0,0 +1,82 @@
+package sflag
+
+import (
+	"reflect"
+	"sync"
+)
+
+// fieldCache caches the tagged fields of the struct types visited by
+// visitFields, keyed by fieldCacheKey, so that the tags of a struct
+// type are parsed only once, e.g. by programs building a FlagSet per
+// subcommand or per request. The cached fields must not be mutated.
+var fieldCache sync.Map
+
+// fieldCacheKey identifies the struct type and the options the tagged
+// fields of the type depend on.
+type fieldCacheKey struct {
+	typ          reflect.Type
+	tagKey       string
+	nestedPrefix bool
+	nestedSep    string
+	untagged     bool
+	strictTags   bool
+}
+
+// newFieldCacheKey returns the key of the tagged fields of the struct
+// type typ visited with the options o. The fields can't be cached if
+// a custom name function is used, as the names of the flags could
+// then vary between calls.
+func newFieldCacheKey(typ reflect.Type, o *options) (fieldCacheKey, bool) {
+	if reflect.ValueOf(o.nameFunc).Pointer() != reflect.ValueOf(DefaultFlagName).Pointer() {
+		return fieldCacheKey{}, false
+	}
+	return fieldCacheKey{
+		typ:          typ,
+		tagKey:       o.tagKey,
+		nestedPrefix: o.nestedPrefix,
+		nestedSep:    o.nestedSep,
+		untagged:     o.untagged,
+		strictTags:   o.strictTags,
+	}, true
+}
+
+// cachedFields returns the tagged fields of the struct type typ, in
+// the order in which they are visited, from the cache if possible.
+func cachedFields(typ reflect.Type, o *options) ([]*taggedField, error) {
+	if err := o.checkNestedSep(); err != nil {
+		return nil, err
+	}
+	key, ok := newFieldCacheKey(typ, o)
+	if ok {
+		if fields, found := fieldCache.Load(key); found {
+			return fields.([]*taggedField), nil
+		}
+	}
+	var fields []*taggedField
+	fv := &fieldVisitor{
+		o: o,
+		fn: func(f *taggedField) error {
+			fields = append(fields, f)
+			return nil
+		},
+		visiting: make(map[reflect.Type]bool),
+	}
+	if err := fv.visit(typ, nil, "", ""); err != nil {
+		return nil, err
+	}
+	if ok {
+		fieldCache.Store(key, fields)
+	}
+	return fields, nil
+}
+
+// clearFieldCache empties the caches, the tagged fields depending on
+// the registered types.
+func clearFieldCache() {
+	for _, c := range []*sync.Map{&fieldCache, &argsCache} {
+		c.Range(func(key, _ any) bool {
+			c.Delete(key)
+			return true
+		})
+	}
+}
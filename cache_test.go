@@ -0,0 +1,71 @@
+package sflag
+
+import (
+	"reflect"
+	"testing"
+)
+
+func BenchmarkAddFlags(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		cold bool
+	}{{"cold", true}, {"warm", false}} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if bc.cold {
+					clearFieldCache()
+				}
+				var c benchConfig
+				newBenchFlagSet(b, &c)
+			}
+		})
+	}
+}
+
+func BenchmarkSetFromFlags(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		cold bool
+	}{{"cold", true}, {"warm", false}} {
+		b.Run(bc.name, func(b *testing.B) {
+			var c benchConfig
+			fs := newBenchFlagSet(b, &c)
+			if err := fs.Parse(benchArgs); err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if bc.cold {
+					clearFieldCache()
+				}
+				if err := SetFromFlagsWith(&c, fs); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestFieldCache(t *testing.T) {
+	typ := reflect.TypeOf(benchConfig{})
+	o := newOptions(nil)
+	f1, err := cachedFields(typ, o)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f2, _ := cachedFields(typ, o)
+	if &f1[0] != &f2[0] {
+		t.Error("the tagged fields were visited again")
+	}
+	f3, _ := cachedFields(typ, newOptions([]Option{WithNestedPrefix()}))
+	if f3[len(f3)-1].name != "server.tls" {
+		t.Errorf("got flag %q for other options, want %q", f3[len(f3)-1].name, "server.tls")
+	}
+	clearFieldCache()
+	f4, _ := cachedFields(typ, o)
+	if &f1[0] == &f4[0] {
+		t.Error("the tagged fields were not visited again after clearing the cache")
+	}
+}
@@ -0,0 +1,192 @@
+package sflag
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// CheckRequired checks that all the flags corresponding to the fields
+// of the struct contained in s marked as required have been
+// explicitly set on the command line. The returned error lists all
+// the missing flags. opts must be the options used to add the flags
+// to fs.
+func CheckRequired(s any, fs *flag.FlagSet, opts ...Option) error {
+	if !fs.Parsed() {
+		return errors.New("flag not parsed")
+	}
+	v := reflect.Indirect(reflect.ValueOf(s))
+	if v.Kind() != reflect.Struct {
+		return errors.New("not a struct")
+	}
+	explicit := make(map[string]bool)
+	fs.Visit(func(fl *flag.Flag) {
+		explicit[fl.Name] = true
+	})
+	var missing []string
+	err := visitFields(v.Type(), newFlagSetOptions(fs, opts), func(f *taggedField) error {
+		if _, ok := f.tag.markers["required"]; !ok {
+			return nil
+		}
+		for _, name := range f.names() {
+			if explicit[name] {
+				return nil
+			}
+		}
+		missing = append(missing, "-"+f.name)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required flags: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// WarnDeprecated writes a warning to w for each flag explicitly set on
+// the command line corresponding to a field of the struct contained
+// in s marked as deprecated. The warning includes the message given
+// by the deprecated marker. If w is nil, os.Stderr is used. opts must
+// be the options used to add the flags to fs. Deprecated flags still
+// set their field.
+func WarnDeprecated(s any, fs *flag.FlagSet, w io.Writer, opts ...Option) error {
+	v := reflect.Indirect(reflect.ValueOf(s))
+	if v.Kind() != reflect.Struct {
+		return errors.New("not a struct")
+	}
+	if w == nil {
+		w = os.Stderr
+	}
+	explicit := make(map[string]bool)
+	fs.Visit(func(fl *flag.Flag) {
+		explicit[fl.Name] = true
+	})
+	return visitFields(v.Type(), newFlagSetOptions(fs, opts), func(f *taggedField) error {
+		msg, ok := f.tag.markers["deprecated"]
+		if !ok {
+			return nil
+		}
+		for _, name := range f.names() {
+			if !explicit[name] {
+				continue
+			}
+			if msg == "" {
+				fmt.Fprintf(w, "flag -%s is deprecated\n", name)
+			} else {
+				fmt.Fprintf(w, "flag -%s is deprecated: %s\n", name, msg)
+			}
+		}
+		return nil
+	})
+}
+
+// CheckBindings checks that the flags derived from the fields of the
+// struct contained in s and the flags added by the package to fs
+// match. It catches the drift between the struct and the flags, e.g.
+// after the renaming of a flag. The returned error lists separately
+// the orphaned tags, whose flag isn't defined in fs, and the orphaned
+// flags, which were added by the package to fs but don't correspond
+// to any field. opts must be the options used to add the flags to fs.
+func CheckBindings(s any, fs *flag.FlagSet, opts ...Option) error {
+	v := reflect.Indirect(reflect.ValueOf(s))
+	if v.Kind() != reflect.Struct {
+		return errors.New("not a struct")
+	}
+	o := newFlagSetOptions(fs, opts)
+	fields, err := getFlagFields(v.Type(), o)
+	if err != nil {
+		return err
+	}
+	var orphanedTags []string
+	err = visitFields(v.Type(), o, func(f *taggedField) error {
+		for _, name := range f.names() {
+			if fs.Lookup(name) == nil {
+				orphanedTags = append(orphanedTags, "-"+name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var orphanedFlags []string
+	readFlagSetInfo(fs, func(info *flagSetInfo) {
+		if info == nil {
+			return
+		}
+		for name := range info.added {
+			if fields[name] == nil {
+				orphanedFlags = append(orphanedFlags, "-"+name)
+			}
+		}
+	})
+	sort.Strings(orphanedFlags)
+	var errs []string
+	if len(orphanedTags) > 0 {
+		errs = append(errs, fmt.Sprintf("orphaned tags: %s", strings.Join(orphanedTags, ", ")))
+	}
+	if len(orphanedFlags) > 0 {
+		errs = append(errs, fmt.Sprintf("orphaned flags: %s", strings.Join(orphanedFlags, ", ")))
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// CheckMutex checks that at most one flag of each mutual exclusion
+// group was explicitly set on the command line, the group of a flag
+// being given by the mutex marker of its field. The returned error
+// lists the conflicting flags of each group. opts must be the options
+// used to add the flags to fs.
+func CheckMutex(s any, fs *flag.FlagSet, opts ...Option) error {
+	if !fs.Parsed() {
+		return errors.New("flag not parsed")
+	}
+	v := reflect.Indirect(reflect.ValueOf(s))
+	if v.Kind() != reflect.Struct {
+		return errors.New("not a struct")
+	}
+	explicit := make(map[string]bool)
+	fs.Visit(func(fl *flag.Flag) {
+		explicit[fl.Name] = true
+	})
+	var groups []string
+	set := make(map[string][]string)
+	err := visitFields(v.Type(), newFlagSetOptions(fs, opts), func(f *taggedField) error {
+		group := f.tag.markers["mutex"]
+		if group == "" {
+			return nil
+		}
+		if _, ok := set[group]; !ok {
+			groups = append(groups, group)
+			set[group] = nil
+		}
+		for _, name := range f.names() {
+			if explicit[name] {
+				set[group] = append(set[group], "-"+name)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for _, group := range groups {
+		if len(set[group]) > 1 {
+			errs = append(errs, fmt.Sprintf("flags %s can't be used together", strings.Join(set[group], ", ")))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
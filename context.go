@@ -0,0 +1,87 @@
+package sflag
+
+import (
+	"context"
+	"flag"
+	"fmt"
+)
+
+// ContextSetter is implemented by the flag.Value whose parsing can
+// take time, e.g. because it involves I/O, and must be cancellable.
+// The values of the flags implementing ContextSetter are recorded as
+// is when the flags are parsed and given to SetContext when the
+// struct fields are set, see SetFromFlagsContext.
+type ContextSetter interface {
+	SetContext(ctx context.Context, s string) error
+}
+
+// contextValue is a flag.Value recording the values given to its Set
+// method to pass them later to the SetContext method of the wrapped
+// flag.Value.
+type contextValue struct {
+	flag.Value
+	pending []string
+}
+
+func (v *contextValue) Set(s string) error {
+	v.pending = append(v.pending, s)
+	return nil
+}
+
+func (v *contextValue) String() string {
+	if v.Value == nil {
+		return ""
+	}
+	if len(v.pending) > 0 {
+		return v.pending[len(v.pending)-1]
+	}
+	return v.Value.String()
+}
+
+func (v *contextValue) IsBoolFlag() bool {
+	return v.Value != nil && isBoolValue(v.Value)
+}
+
+func (v *contextValue) unwrap() flag.Value {
+	return v.Value
+}
+
+// apply passes the recorded values to the wrapped flag.Value.
+func (v *contextValue) apply(ctx context.Context) error {
+	for len(v.pending) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		s := v.pending[0]
+		v.pending = v.pending[1:]
+		if err := v.Value.(ContextSetter).SetContext(ctx, s); err != nil {
+			return fmt.Errorf("invalid value %q: %w", s, err)
+		}
+	}
+	return nil
+}
+
+// applyContextValues passes the values recorded by the flags of fs
+// corresponding to fields to the SetContext method of their value.
+func applyContextValues(ctx context.Context, fs *flag.FlagSet, fields map[string]*taggedField) error {
+	var err error
+	fs.VisitAll(func(fl *flag.Flag) {
+		if err != nil || fields[fl.Name] == nil {
+			return
+		}
+		for v := fl.Value; v != nil; {
+			if cv, ok := v.(*contextValue); ok {
+				if e := cv.apply(ctx); e != nil {
+					err = fmt.Errorf("flag %q: %w", fl.Name, e)
+				}
+				return
+			}
+			u, ok := v.(unwrapper)
+			if !ok {
+				return
+			}
+			v = u.unwrap()
+		}
+	})
+	return err
+}
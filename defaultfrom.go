@@ -0,0 +1,81 @@
+package sflag
+
+import (
+	"flag"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// applyDefaultFrom sets the fields of the struct v with a defaultfrom
+// marker whose flag wasn't explicitly given and has no default value
+// of its own to the value of the field of the referenced flag, fields
+// giving the field corresponding to each flag of fs and explicit the
+// paths of the fields whose flag was given. The referenced fields are
+// set first, a circular reference being an error. A referenced flag
+// defined in fs but missing from fields is ignored.
+func applyDefaultFrom(v reflect.Value, fs *flag.FlagSet, fields map[string]*taggedField, explicit map[string]bool, o *options) error {
+	const (
+		visiting = iota + 1
+		done
+	)
+	state := make(map[string]int)
+	var resolve func(f *taggedField, chain []string) error
+	resolve = func(f *taggedField, chain []string) error {
+		ref := f.tag.markers["defaultfrom"]
+		if ref == "" || state[f.path] == done {
+			return nil
+		}
+		chain = append(chain, "-"+f.name)
+		if state[f.path] == visiting {
+			return fieldError(InvalidTag, f.path, f.name, "circular defaultfrom reference %s", strings.Join(chain, " -> "))
+		}
+		state[f.path] = visiting
+		src := fields[ref]
+		if src == nil {
+			if fs.Lookup(ref) == nil {
+				return fieldError(InvalidTag, f.path, f.name, "unknown flag %q in defaultfrom marker of flag %q", ref, f.name)
+			}
+			state[f.path] = done
+			return nil
+		}
+		if err := resolve(src, chain); err != nil {
+			return err
+		}
+		state[f.path] = done
+		if explicit[f.path] || hasDefault(f) {
+			return nil
+		}
+		if !src.IsExported() {
+			return fieldError(InvalidTag, f.path, f.name, "flag %q can't default from flag %q bound to an unexported field", f.name, ref)
+		}
+		fiv := fieldByIndex(v, f.index)
+		if !o.alwaysApplyDefaults && !fiv.IsZero() {
+			return nil
+		}
+		sv := fieldByIndex(v, src.index)
+		if !sv.Type().AssignableTo(fiv.Type()) {
+			if !canConvert(sv.Type(), fiv.Type()) {
+				return fieldError(ConversionFailed, f.path, f.name, "flag %q: cannot convert value of type %q of flag %q to field of type %q", f.name, sv.Type(), ref, fiv.Type())
+			}
+			if overflows(sv, fiv.Type()) {
+				return fieldError(ConversionFailed, f.path, f.name, "flag %q: value %v of flag %q overflows field of type %q", f.name, sv, ref, fiv.Type())
+			}
+			sv = sv.Convert(fiv.Type())
+		}
+		target := setterTarget(f, fiv)
+		target.Set(sv)
+		return callSetter(v, f, f.name, target)
+	}
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := resolve(fields[name], nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
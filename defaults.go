@@ -0,0 +1,95 @@
+package sflag
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var (
+	defaultsMu sync.RWMutex
+	defaults   = make(map[string]any)
+)
+
+// RegisterDefault registers v as the default value called name. A
+// tag default of the form "@name", e.g. `flag:"timeout,@timeout,request
+// timeout"`, references the registered default value instead of
+// giving it as a string, which keeps the default values defined in Go
+// type-safe. The type of v must be assignable or convertible to the
+// type of the field, e.g. an untyped constant given as int for an
+// int64 field, an integer not being convertible to a string. A tag
+// default starting with "@@" is the literal default value without its
+// first "@". The defaults of the fields with the fromfile marker are
+// never resolved.
+func RegisterDefault(name string, v any) {
+	defaultsMu.Lock()
+	defer defaultsMu.Unlock()
+	defaults[name] = v
+}
+
+// lookupDefault returns the default value registered as name.
+func lookupDefault(name string) (any, bool) {
+	defaultsMu.RLock()
+	defer defaultsMu.RUnlock()
+	v, ok := defaults[name]
+	return v, ok
+}
+
+// emptyDefault reports whether a field of type typ and tag ft has the
+// empty string as default value, i.e. its tag gives an explicitly
+// empty default and the field holds strings. For the other fields, an
+// explicitly empty default means no default value like an empty one.
+func emptyDefault(typ reflect.Type, ft *flagTag) bool {
+	if !ft.emptyDeflt {
+		return false
+	}
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ.Kind() == reflect.Slice {
+		typ = typ.Elem()
+	}
+	return typ.Kind() == reflect.String
+}
+
+// tagDefaults returns the default values of a flag of type typ and
+// tag ft whose tag default is deflt, resolving a reference to a
+// registered default value, see RegisterDefault. A multi-valued
+// default, e.g. of a slice field, gives several values.
+func tagDefaults(deflt string, typ reflect.Type, ft *flagTag) ([]string, error) {
+	if deflt == "" {
+		if emptyDefault(typ, ft) {
+			return []string{""}, nil
+		}
+		return nil, nil
+	}
+	if _, ok := ft.markers["fromfile"]; ok {
+		return []string{deflt}, nil
+	}
+	if strings.HasPrefix(deflt, "@@") {
+		return []string{deflt[1:]}, nil
+	}
+	name, ok := strings.CutPrefix(deflt, "@")
+	if !ok {
+		return []string{deflt}, nil
+	}
+	dv, ok := lookupDefault(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown default %q", name)
+	}
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	v := reflect.ValueOf(dv)
+	if !v.IsValid() {
+		return nil, fmt.Errorf("nil default %q", name)
+	}
+	if !v.Type().AssignableTo(typ) {
+		if !canConvert(v.Type(), typ) {
+			return nil, fmt.Errorf("default %q of type %q can't be assigned to field of type %q", name, v.Type(), typ)
+		}
+		v = v.Convert(typ)
+	}
+	return formatField(v, ft), nil
+}
@@ -0,0 +1,76 @@
+package sflag
+
+import (
+	"errors"
+	"flag"
+	"reflect"
+)
+
+// FlagInfo describes a flag derived from a struct field.
+type FlagInfo struct {
+	// Name is the name of the flag
+	Name string
+	// Aliases are the alternative names of the flag
+	Aliases []string
+	// Default is the default value of the flag, as shown in the
+	// help message
+	Default string
+	// Help is the help message of the flag
+	Help string
+	// Field is the dot separated list of the names of the fields
+	// leading to the field from the root struct
+	Field string
+	// Type is the type of the flag as shown by flag.PrintDefaults,
+	// e.g. "int" or "duration", or the Go type of the field if the
+	// flag package has no specific name for it
+	Type string
+}
+
+// DescribeFlags returns the description of the flags that AddFlags
+// would add for the struct contained in s, in the order of the struct
+// fields. It doesn't require a flag.FlagSet.
+func DescribeFlags(s any, opts ...Option) ([]FlagInfo, error) {
+	v := reflect.Indirect(reflect.ValueOf(s))
+	if v.Kind() != reflect.Struct {
+		return nil, errors.New("not a struct")
+	}
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	defer forgetFlagSet(fs)
+	var infos []FlagInfo
+	o := newOptions(opts)
+	err := visitFields(v.Type(), o, func(f *taggedField) error {
+		if err := addFlag(fs, f, reflect.Value{}, o); err != nil {
+			return err
+		}
+		fl := fs.Lookup(f.name)
+		infos = append(infos, FlagInfo{
+			Name:    f.name,
+			Aliases: f.aliases,
+			Default: fl.DefValue,
+			Help:    f.tag.help,
+			Field:   f.path,
+			Type:    flagType(fl, f.Type),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
+
+// flagType returns the name of the type of fl, typ being the type of
+// the field from which fl is derived.
+func flagType(fl *flag.Flag, typ reflect.Type) string {
+	name, _ := flag.UnquoteUsage(&flag.Flag{Value: fl.Value})
+	switch name {
+	case "":
+		return "bool"
+	case "value":
+		if typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		return typ.String()
+	}
+	return name
+}
@@ -0,0 +1,223 @@
+// Package sflag defines the flags of a flag.FlagSet from the tagged
+// fields of a struct and sets the fields from the flags once parsed,
+// e.g.
+//
+//	type Config struct {
+//		Host string `flag:"host,localhost,host to connect to"`
+//		Port int    `flag:"port,8080,port to connect to|min=1"`
+//	}
+//
+//	var c Config
+//	sflag.AddFlags(flag.CommandLine, &c)
+//	flag.Parse()
+//	sflag.SetFromFlags(&c, flag.CommandLine)
+//
+// # Tags
+//
+// The value associated with the tag key, TagKey unless WithTagKey is
+// given, must be a comma separated list of three items:
+//   - the name of the flag
+//   - the default value for the flag
+//   - the help message for the flag
+//
+// Alternatively, the value associated with the tag key can be a
+// semicolon separated list of key=value pairs, e.g.
+// "name=port;default=8080;usage=listen port;required". The name,
+// default and usage keys hold respectively the name, the default
+// value and the help message of the flag, the other keys being
+// markers. This syntax is used as soon as the first item of the list
+// contains a "=".
+//
+// An empty default value in the first syntax, e.g. `flag:"name,,help"`,
+// means that the flag has no default value, its value being the zero
+// value of its type. An empty default key in the second syntax, e.g.
+// "name=prefix;default=;usage=help", sets an explicitly empty default
+// value instead: for a string field, the flag has the empty string as
+// default value, which e.g. sets a nil *string field to a pointer to
+// the empty string and, with WithAlwaysApplyDefaults, a non-empty
+// string field to the empty string, and for a string slice field
+// without sep marker, it gives a single empty element. For the other
+// types, e.g. the numeric fields, it means no default value like in
+// the first syntax.
+//
+// In both syntaxes, the characters ",", "|", ";" and "\" can be
+// escaped with a backslash to be used literally, e.g. the field tag
+// `flag:"list,a\\,b,items"` defines the flag "list" with the default
+// value "a,b".
+//
+// If the name of the flag is empty, e.g. `flag:",10,max connections"`,
+// it is derived from the field, see DefaultFlagName and WithNameFunc.
+//
+// A default value of the form "@name", e.g. `flag:"timeout,@timeout,
+// request timeout"`, references a default value registered with
+// RegisterDefault.
+//
+// A field whose tag value is "-" is skipped, whatever its type.
+//
+// # Markers
+//
+// The help message can be followed by a list of markers separated by
+// "|", each marker being either a bare key or a key=value pair. The
+// unknown markers are ignored, unless WithStrictTags is given. The
+// following markers are recognized:
+//   - sep=<separator>: for slice and map fields, split each
+//     occurrence of the flag on the separator, an empty value
+//     producing no element, e.g. -weight a=1,b=2, for array fields,
+//     the separator of the elements, "," by default
+//   - shellwords: for slice fields, split each occurrence of the flag
+//     into words like a shell does, respecting single and double
+//     quotes and backslash escapes, e.g. -args '--foo "bar baz"'
+//     appends "--foo" and "bar baz", malformed quoting being an error
+//   - layout=<layout>: for time.Time fields, the layout used to parse
+//     the value of the flag, time.RFC3339 by default
+//   - required: the flag must be set on the command line, see
+//     CheckRequired
+//   - env=<variable>: if the environment variable is set and not
+//     empty, its value is used as the default value of the flag
+//     instead of the default value given in the tag
+//   - alias=<names>: a comma separated list of alternative names for
+//     the flag, all sharing the value of the flag
+//   - count: for integer fields, the flag doesn't take a value and
+//     counts the number of times it is given, starting from the
+//     default value, e.g. -v -v -v. A value can still be given
+//     explicitly, e.g. -v=3, -v=false resetting the count to 0. A count
+//     out of the range of the field is an error
+//   - negatable: for boolean fields, an additional flag named after
+//     the flag prefixed with "no-" sets the field to false, e.g.
+//     -no-feature, the two flags can't be used together
+//   - hidden: the flag is not printed by PrintVisibleDefaults
+//   - deprecated=<message>: the flag is deprecated, WarnDeprecated
+//     printing the message when the flag is used
+//   - oneof=<values>: the value of the flag must be one of the comma
+//     separated list of values
+//   - ignorecase: the values of the oneof marker are compared to the
+//     value of the flag ignoring case
+//   - oneofci=<values>: like oneof|ignorecase, but the value of the
+//     flag is replaced by the matching value of the list, e.g. -level
+//     WARN sets the field to "warn" for oneofci=debug,info,warn
+//   - min=<value>, max=<value>: for numeric fields, including
+//     durations and byte sizes, the value of the flag must be greater
+//     than or equal to min and lower than or equal to max
+//   - encoding=<encoding>: for byte slice fields, the base64 encoding
+//     of the value of the flag, either std (the default), url, rawstd
+//     or rawurl, the raw encodings omitting padding
+//   - include=<names>, exclude=<names>: for nested struct fields, see
+//     Field types
+//   - base=<base>: for big.Int fields, the base of the value of the
+//     flag, 0 by default, in which case the prefixes 0b, 0o and 0x
+//     select the base like for the integer fields
+//   - group=<name>: the group under which the flag is printed by
+//     PrintGroupedDefaults
+//   - mutex=<group>: at most one flag of the mutual exclusion group
+//     can be given on the command line, see CheckMutex
+//   - duration: for int64 fields, the value of the flag is parsed
+//     like a time.Duration field, e.g. for a named duration type
+//   - unit=<unit>: for duration fields, the unit of a bare number
+//     given as value of the flag, e.g. with "unit=s", -timeout 30 is
+//     the same as -timeout 30s, the values with a unit being still
+//     accepted
+//   - fromfile: a value of the flag starting with "@" is replaced by
+//     the content of the file named after the "@", e.g. -cert
+//     @/etc/cert.pem
+//   - bytesize: for integer fields, the value of the flag is a human
+//     readable size, e.g. 10MB or 4KiB, using either SI (KB, MB, ...)
+//     or IEC (KiB, MiB, ...) units
+//   - rune: for int32 fields, e.g. rune fields, the value of the flag
+//     is a single character, e.g. -sep ';', instead of a number
+//   - sensitive: the value of the flag, e.g. a password, is redacted
+//     by MarshalArgs and DumpValues
+//   - binary=<encoding>: for the types implementing
+//     encoding.BinaryUnmarshaler, the value of the flag is decoded
+//     using the encoding, either base64, base64url or hex, and parsed
+//     using the UnmarshalBinary method, the MarshalBinary method, if
+//     any, being used to format the value
+//   - defaultfrom=<name>: if the flag isn't given on the command line
+//     and has no default value, its field is set to the value of the
+//     field of the flag name once set, e.g. "defaultfrom=bind-addr",
+//     circular references being an error
+//   - setter=<method>: the field is set by calling the method of the
+//     struct holding the field, e.g. "setter=SetTimeout", with the
+//     value of the flag instead of being assigned, the field may then
+//     be unexported, see Field types
+//
+// # Field types
+//
+// Integer fields of any width are turned into int64 flags if signed
+// and uint64 flags otherwise, parsed using base 0, i.e. the prefixes
+// 0b, 0o and 0x select the base. A value out of the range of the
+// field, e.g. of an int field on a 32-bit platform, is rejected when
+// the struct fields are set instead of being truncated, and a default
+// value out of range is rejected when the flags are added. Likewise,
+// a value converted to the type of its field, e.g. the value of a
+// flag added by a handler registered with RegisterType, is checked
+// against the range of the field.
+//
+// Slice fields are turned into flags that can be repeated, each
+// occurrence of the flag appending an element to the slice. The
+// default value, if any, is parsed as a single initial element which
+// is discarded as soon as the flag is given on the command line.
+//
+// Likewise, map fields are turned into flags that can be repeated,
+// each occurrence of the flag adding an entry given as key=value to
+// the map, e.g. -weight a=1 for a map[string]int field. Only the
+// first "=" separates the key from the value. The keys and the values
+// are parsed according to their type like the elements of the slice
+// fields, e.g. durations for a map[string]time.Duration field.
+//
+// Fields whose type implements encoding.TextUnmarshaler are parsed
+// using their UnmarshalText method and formatted using their
+// MarshalText method if any.
+//
+// The net.IP, net.IPNet, url.URL, netip.Addr, netip.Prefix and
+// netip.AddrPort fields are parsed using respectively net.ParseIP,
+// net.ParseCIDR, url.Parse, netip.ParseAddr, netip.ParsePrefix and
+// netip.ParseAddrPort, an empty value being rejected except for
+// url.URL.
+//
+// Interface fields take the name of one of the implementations
+// registered with RegisterImplementation.
+//
+// Array fields take all their elements at once, separated by the
+// separator given by the sep marker, e.g. -coords 1,2,3 for a
+// [3]float64 field. The number of elements must match the length of
+// the array.
+//
+// Byte slice fields are not repeatable, the value of the flag being
+// base64 encoded, see the encoding marker.
+//
+// A struct or pointer to struct field carrying a tag, whose type
+// can't be turned into a flag, e.g. `flag:"db,,database options"`,
+// holds nested flags like an untagged one, the name of its tag being
+// used as prefix instead of the lowercased name of the field if
+// WithNestedPrefix is given. The include and exclude markers of the
+// tag restrict the nested flags to a comma separated list of names,
+// e.g. "include=host,port", the names being given without the prefix
+// of the nested struct.
+//
+// A nil pointer field is left nil unless its flag is given on the
+// command line or has a default value, e.g. a *bool field tells apart
+// a flag not given from a flag set to false. This holds for the
+// pointer to slice or map fields too, e.g. a nil *[]string field tells
+// that its flag is neither given nor has a default value.
+//
+// A pointer field implementing flag.Value which is not nil when the
+// flags are added is registered as is, parsing the flag mutating the
+// value pointed to by the field instead of a new value. Likewise, the
+// current value of a non-pointer field implementing flag.Value, if
+// not the zero value, is the default value of the flag unless the tag
+// gives one.
+//
+// A []string field tagged `flag:"@args"` receives the positional
+// arguments left after parsing, i.e. fs.Args(), when the struct
+// fields are set from the flags. It's left untouched if there are no
+// positional arguments. At most one field may be tagged this way.
+//
+// A field with the setter marker, e.g. `flag:"timeout,5s,request
+// timeout|setter=SetTimeout"`, is set by calling the named method on
+// the pointer to the struct holding the field, which must take a
+// single parameter assignable or convertible from the type of the
+// field and return nothing or an error, the error being returned by
+// the functions setting the fields. Unlike the other fields, such a
+// field may be unexported, its current value being then ignored, e.g.
+// by AddFlagsWithDefaults, MarshalArgs and DumpValues.
+package sflag
@@ -0,0 +1,72 @@
+package sflag
+
+import "fmt"
+
+// ErrorKind identifies the kind of problem reported by a FlagError.
+type ErrorKind int
+
+const (
+	// InvalidTag reports a malformed tag or marker.
+	InvalidTag ErrorKind = iota + 1
+	// DuplicateFlag reports a flag name already in use.
+	DuplicateFlag
+	// UnsupportedType reports a field whose type can't be turned
+	// into a flag.
+	UnsupportedType
+	// InvalidDefault reports a default value that can't be parsed,
+	// whether it comes from the tag, from an environment variable
+	// or from the current value of the field.
+	InvalidDefault
+	// ConversionFailed reports a flag value that can't be assigned
+	// to its field.
+	ConversionFailed
+)
+
+var errorKindNames = map[ErrorKind]string{
+	InvalidTag:       "invalid tag",
+	DuplicateFlag:    "duplicate flag",
+	UnsupportedType:  "unsupported type",
+	InvalidDefault:   "invalid default",
+	ConversionFailed: "conversion failed",
+}
+
+func (k ErrorKind) String() string {
+	if name, ok := errorKindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("ErrorKind(%d)", int(k))
+}
+
+// FlagError is the error returned when a struct field can't be bound
+// to its flag. It can be retrieved with errors.As to inspect the
+// offending field and the kind of problem.
+type FlagError struct {
+	// Field is the path of the field, the names of the nested
+	// fields being separated by dots
+	Field string
+	// Flag is the name of the flag, empty if unknown
+	Flag string
+	Kind ErrorKind
+	// Err is the cause of the error
+	Err error
+}
+
+func (e *FlagError) Error() string {
+	return fmt.Sprintf("field %s: %v", e.Field, e.Err)
+}
+
+func (e *FlagError) Unwrap() error {
+	return e.Err
+}
+
+// fieldError returns a *FlagError of the given kind for the field
+// whose path is path and the flag name, the cause being formatted
+// according to format.
+func fieldError(kind ErrorKind, path string, name string, format string, args ...any) error {
+	return &FlagError{
+		Field: path,
+		Flag:  name,
+		Kind:  kind,
+		Err:   fmt.Errorf(format, args...),
+	}
+}
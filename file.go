@@ -0,0 +1,79 @@
+package sflag
+
+import (
+	"flag"
+	"os"
+	"strings"
+)
+
+// fileValue is a flag.Value reading the content of the file named
+// after a leading "@" in the values given to its Set method before
+// passing them to the wrapped flag.Value. The values without leading
+// "@" are passed as is. The last value given is returned by String
+// so that the content of the file, e.g. a secret, isn't printed in the
+// help message.
+type fileValue struct {
+	flag.Value
+	raw *string
+}
+
+// read returns the content of the file named by s if s starts with
+// "@", s otherwise.
+func (v *fileValue) read(s string) (string, error) {
+	name, ok := strings.CutPrefix(s, "@")
+	if !ok {
+		return s, nil
+	}
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func (v *fileValue) Set(s string) error {
+	content, err := v.read(s)
+	if err != nil {
+		return err
+	}
+	if err := v.Value.Set(content); err != nil {
+		return err
+	}
+	v.raw = &s
+	return nil
+}
+
+func (v *fileValue) String() string {
+	if v.Value == nil {
+		return ""
+	}
+	if v.raw != nil {
+		return *v.raw
+	}
+	return v.Value.String()
+}
+
+func (v *fileValue) setDefault(s string) error {
+	content, err := v.read(s)
+	if err != nil {
+		return err
+	}
+	if ds, ok := v.Value.(defaultSetter); ok {
+		err = ds.setDefault(content)
+	} else {
+		err = v.Value.Set(content)
+	}
+	if err != nil {
+		return err
+	}
+	v.raw = &s
+	return nil
+}
+
+func (v *fileValue) IsBoolFlag() bool {
+	return v.Value != nil && isBoolValue(v.Value)
+}
+
+func (v *fileValue) unwrap() flag.Value {
+	return v.Value
+}
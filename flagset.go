@@ -0,0 +1,92 @@
+package sflag
+
+import (
+	"flag"
+	"sync"
+)
+
+// flagSetInfo holds the informations about the flags added by the
+// package to a flag.FlagSet that can't be stored in the flag.FlagSet
+// itself.
+type flagSetInfo struct {
+	// added holds the names of the flags added by the package
+	added map[string]bool
+	// order holds the names of the flags added by the package in
+	// the order they were added
+	order []string
+	// hidden holds the names of the hidden flags
+	hidden map[string]bool
+	// sensitive holds the names of the flags whose value must be
+	// redacted
+	sensitive map[string]bool
+	// groups maps the names of the flags to their group
+	groups map[string]string
+	// groupOrder holds the groups in the order they were added
+	groupOrder []string
+	// schemas holds the schemas added by AddFlagsFromSchema
+	schemas []boundSchema
+	// lowerNames maps the lowercased names of the flags added with
+	// the WithCaseInsensitiveNames option to their names
+	lowerNames map[string]string
+	// untagged is set if the flags were added by AddFlagsAuto
+	untagged bool
+}
+
+// flagSets holds the informations about the FlagSets, keyed by a
+// flagSetKey which doesn't keep the FlagSets alive when supported by
+// the toolchain, the informations about a FlagSet being then dropped
+// once the FlagSet is garbage collected, see watchFlagSet.
+var (
+	flagSetsMu sync.Mutex
+	flagSets   = make(map[flagSetKey]*flagSetInfo)
+)
+
+// updateFlagSetInfo calls fn with the informations about fs, creating
+// them if needed.
+func updateFlagSetInfo(fs *flag.FlagSet, fn func(info *flagSetInfo)) {
+	flagSetsMu.Lock()
+	defer flagSetsMu.Unlock()
+	key := newFlagSetKey(fs)
+	info := flagSets[key]
+	if info == nil {
+		info = &flagSetInfo{
+			added:      make(map[string]bool),
+			hidden:     make(map[string]bool),
+			sensitive:  make(map[string]bool),
+			groups:     make(map[string]string),
+			lowerNames: make(map[string]string),
+		}
+		flagSets[key] = info
+		watchFlagSet(fs, key)
+	}
+	fn(info)
+}
+
+// readFlagSetInfo calls fn with the informations about fs, which
+// can't be modified by fn. info is nil if the package has no
+// informations about fs.
+func readFlagSetInfo(fs *flag.FlagSet, fn func(info *flagSetInfo)) {
+	flagSetsMu.Lock()
+	defer flagSetsMu.Unlock()
+	fn(flagSets[newFlagSetKey(fs)])
+}
+
+// newFlagSetOptions is like newOptions but it also applies the options
+// implied by the way the flags were added to fs, e.g. by AddFlagsAuto.
+func newFlagSetOptions(fs *flag.FlagSet, opts []Option) *options {
+	o := newOptions(opts)
+	readFlagSetInfo(fs, func(info *flagSetInfo) {
+		if info != nil && info.untagged {
+			o.untagged = true
+		}
+	})
+	return o
+}
+
+// forgetFlagSet drops the informations about fs. It must be called
+// when the package is done with a flag.FlagSet used internally.
+func forgetFlagSet(fs *flag.FlagSet) {
+	flagSetsMu.Lock()
+	defer flagSetsMu.Unlock()
+	delete(flagSets, newFlagSetKey(fs))
+}
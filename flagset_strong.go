@@ -0,0 +1,17 @@
+//go:build !go1.24
+
+package sflag
+
+import "flag"
+
+// flagSetKey identifies a FlagSet. Weak pointers being unavailable,
+// it keeps the FlagSet alive.
+type flagSetKey = *flag.FlagSet
+
+func newFlagSetKey(fs *flag.FlagSet) flagSetKey {
+	return fs
+}
+
+// watchFlagSet does nothing: without weak pointers, the informations
+// about fs are kept until the program exits.
+func watchFlagSet(fs *flag.FlagSet, key flagSetKey) {}
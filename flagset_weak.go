@@ -0,0 +1,27 @@
+//go:build go1.24
+
+package sflag
+
+import (
+	"flag"
+	"runtime"
+	"weak"
+)
+
+// flagSetKey identifies a FlagSet without keeping it alive.
+type flagSetKey = weak.Pointer[flag.FlagSet]
+
+func newFlagSetKey(fs *flag.FlagSet) flagSetKey {
+	return weak.Make(fs)
+}
+
+// watchFlagSet drops the informations about fs, identified by key,
+// once fs is garbage collected, e.g. for the FlagSets built per
+// request or per subcommand.
+func watchFlagSet(fs *flag.FlagSet, key flagSetKey) {
+	runtime.AddCleanup(fs, func(key flagSetKey) {
+		flagSetsMu.Lock()
+		defer flagSetsMu.Unlock()
+		delete(flagSets, key)
+	}, key)
+}
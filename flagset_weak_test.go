@@ -0,0 +1,36 @@
+//go:build go1.24
+
+package sflag
+
+import (
+	"flag"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestFlagSetInfoDropped(t *testing.T) {
+	count := func() int {
+		flagSetsMu.Lock()
+		defer flagSetsMu.Unlock()
+		return len(flagSets)
+	}
+	before := count()
+	for i := 0; i < 100; i++ {
+		var s struct {
+			Port int `flag:"port,80,port"`
+		}
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		if err := AddFlagsWith(fs, &s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for count() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d FlagSets still recorded, want %d", count(), before)
+		}
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -1,3 +1,3 @@
 module github.com/montag451/go-sflag
 
-go 1.19
+go 1.20
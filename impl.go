@@ -0,0 +1,98 @@
+package sflag
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	implsMu sync.RWMutex
+	impls   = make(map[reflect.Type]map[string]func() (any, error))
+)
+
+// RegisterImplementation registers fn as the constructor of the
+// implementation called name of the interface type iface. The flags
+// derived from the fields of type iface take the name of an
+// implementation, e.g. `flag:"backend,stdout,output backend"`, the
+// field being set to the value returned by the constructor of the
+// chosen implementation. The value returned by fn must implement
+// iface. RegisterImplementation panics if iface isn't an interface
+// type.
+func RegisterImplementation(iface reflect.Type, name string, fn func() (any, error)) {
+	if iface.Kind() != reflect.Interface {
+		panic(fmt.Sprintf("sflag: %s is not an interface type", iface))
+	}
+	implsMu.Lock()
+	defer implsMu.Unlock()
+	if impls[iface] == nil {
+		impls[iface] = make(map[string]func() (any, error))
+	}
+	impls[iface][name] = fn
+}
+
+// lookupImplementations returns the sorted names of the
+// implementations registered for the interface type iface.
+func lookupImplementations(iface reflect.Type) []string {
+	implsMu.RLock()
+	defer implsMu.RUnlock()
+	names := make([]string, 0, len(impls[iface]))
+	for name := range impls[iface] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// implValue is a flag.Value holding the name of an implementation of
+// the interface type iface.
+type implValue struct {
+	name  string
+	iface reflect.Type
+}
+
+func newImplValue(iface reflect.Type) (*implValue, error) {
+	if len(lookupImplementations(iface)) == 0 {
+		return nil, fmt.Errorf("no implementation registered for %q", iface)
+	}
+	return &implValue{iface: iface}, nil
+}
+
+func (v *implValue) Set(s string) error {
+	names := lookupImplementations(v.iface)
+	i := sort.SearchStrings(names, s)
+	if i == len(names) || names[i] != s {
+		return fmt.Errorf("unknown implementation %q, expected one of %s", s, strings.Join(names, ", "))
+	}
+	v.name = s
+	return nil
+}
+
+func (v *implValue) String() string {
+	if v == nil {
+		return ""
+	}
+	return v.name
+}
+
+func (v *implValue) Get() any {
+	return v.name
+}
+
+// construct returns a new instance of the chosen implementation.
+func (v *implValue) construct() (reflect.Value, error) {
+	implsMu.RLock()
+	fn := impls[v.iface][v.name]
+	implsMu.RUnlock()
+	obj, err := fn()
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("implementation %q: %w", v.name, err)
+	}
+	ov := reflect.ValueOf(obj)
+	if !ov.IsValid() || !ov.Type().Implements(v.iface) {
+		return reflect.Value{}, fmt.Errorf("implementation %q: value of type %T doesn't implement %q", v.name, obj, v.iface)
+	}
+	return ov, nil
+}
@@ -0,0 +1,24 @@
+package sflag
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+)
+
+// LoadDefaultsJSON decodes the JSON object read from r into the
+// struct pointed to by s, following the rules of encoding/json: only
+// the fields present in the object are modified. It is meant to seed
+// the struct with the content of a configuration file before calling
+// AddFlagsWithDefaults, which turns the values read from the file
+// into the default values of the flags. SetFromFlags then preserves
+// these values unless the flags are explicitly given on the command
+// line, the command line taking precedence over the file.
+func LoadDefaultsJSON(s any, r io.Reader) error {
+	v := reflect.ValueOf(s)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return errors.New("not a pointer to a struct")
+	}
+	return json.NewDecoder(r).Decode(s)
+}
@@ -0,0 +1,236 @@
+package sflag
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// redacted replaces the value of the flags with the sensitive marker.
+const redacted = "***"
+
+// MarshalArgs returns the command line arguments, as -name=value
+// entries, that set the flags derived from the struct contained in s
+// to the current value of its fields. Only the fields whose value
+// differs from the default value given in their tag are considered,
+// the environment variables of the env markers being ignored. The
+// slice and map fields give one entry per element, an element
+// containing the separator of the sep marker being an error. The
+// fields with the bytesize marker are given with a unit, e.g. 2KiB,
+// like DumpValues gives them. It lets a program log its effective
+// configuration in a form that can be used to run it again. The value
+// of the fields with the sensitive marker is replaced by "***", in a
+// single entry for the slice and map fields.
+func MarshalArgs(s any, opts ...Option) ([]string, error) {
+	v := reflect.Indirect(reflect.ValueOf(s))
+	if v.Kind() != reflect.Struct {
+		return nil, errors.New("not a struct")
+	}
+	o := newOptions(opts)
+	// The flags of dfs hold the default values and the flags of cfs
+	// the current values
+	dfs := flag.NewFlagSet("", flag.ContinueOnError)
+	defer forgetFlagSet(dfs)
+	cfs := flag.NewFlagSet("", flag.ContinueOnError)
+	defer forgetFlagSet(cfs)
+	var args []string
+	err := visitFields(v.Type(), o, func(f *taggedField) error {
+		fv, err := v.FieldByIndexErr(f.index)
+		if err != nil || !f.IsExported() || fv.IsZero() && f.tag.deflt == "" {
+			return nil
+		}
+		ft := *f.tag
+		ft.markers = make(map[string]string, len(f.tag.markers))
+		for k, m := range f.tag.markers {
+			ft.markers[k] = m
+		}
+		delete(ft.markers, "env")
+		df := *f
+		df.tag = &ft
+		if err := addFlag(dfs, &df, reflect.Value{}, o); err != nil {
+			return err
+		}
+		ct := ft
+		ct.deflt, ct.emptyDeflt = "", false
+		cf := df
+		cf.tag = &ct
+		if err := addFlag(cfs, &cf, reflect.Value{}, o); err != nil {
+			return err
+		}
+		cur := formatField(fv, f.tag)
+		if err := checkSeparator(f, cur); err != nil {
+			return err
+		}
+		fl := cfs.Lookup(f.name)
+		for _, c := range cur {
+			if err := fl.Value.Set(c); err != nil {
+				return fieldError(InvalidDefault, f.path, f.name, "invalid current value %q for flag %q: %w", c, f.name, err)
+			}
+		}
+		if fl.Value.String() == dfs.Lookup(f.name).DefValue {
+			return nil
+		}
+		if _, ok := f.tag.markers["sensitive"]; ok {
+			args = append(args, "-"+f.name+"="+redacted)
+			return nil
+		}
+		for _, c := range cur {
+			args = append(args, "-"+f.name+"="+c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+// DiffArgs returns the command line arguments, as -name=value entries,
+// that set the flags derived from the struct contained in current to
+// the value of the fields which differ from their value in the struct
+// contained in base, e.g. to log the changes made to a baseline
+// configuration. base and current must contain structs of the same
+// type. The values of the fields are compared by the String method of
+// the flag.Value of their flag and are given like MarshalArgs gives
+// them, the value of the fields with the sensitive marker being
+// replaced by "***".
+func DiffArgs(base any, current any, opts ...Option) ([]string, error) {
+	bv := reflect.Indirect(reflect.ValueOf(base))
+	cv := reflect.Indirect(reflect.ValueOf(current))
+	if bv.Kind() != reflect.Struct || cv.Kind() != reflect.Struct {
+		return nil, errors.New("not a struct")
+	}
+	if bv.Type() != cv.Type() {
+		return nil, fmt.Errorf("mismatched struct types %q and %q", bv.Type(), cv.Type())
+	}
+	o := newOptions(opts)
+	// The flags of bfs hold the base values and the flags of cfs the
+	// current values
+	bfs := flag.NewFlagSet("", flag.ContinueOnError)
+	defer forgetFlagSet(bfs)
+	cfs := flag.NewFlagSet("", flag.ContinueOnError)
+	defer forgetFlagSet(cfs)
+	var args []string
+	err := visitFields(cv.Type(), o, func(f *taggedField) error {
+		if !f.IsExported() {
+			return nil
+		}
+		ft := *f.tag
+		ft.deflt, ft.emptyDeflt = "", false
+		cf := *f
+		cf.tag = &ft
+		var values [2]string
+		var cur []string
+		for i, x := range []struct {
+			fs *flag.FlagSet
+			v  reflect.Value
+		}{{bfs, bv}, {cfs, cv}} {
+			if err := addFlag(x.fs, &cf, reflect.Value{}, o); err != nil {
+				return err
+			}
+			fv, err := x.v.FieldByIndexErr(f.index)
+			if err != nil {
+				fv = reflect.Zero(f.Type)
+			}
+			cur = formatField(fv, f.tag)
+			if err := checkSeparator(f, cur); err != nil {
+				return err
+			}
+			fl := x.fs.Lookup(f.name)
+			for _, c := range cur {
+				if err := fl.Value.Set(c); err != nil {
+					return fieldError(InvalidDefault, f.path, f.name, "invalid current value %q for flag %q: %w", c, f.name, err)
+				}
+			}
+			values[i] = fl.Value.String()
+		}
+		if values[0] == values[1] {
+			return nil
+		}
+		if _, ok := f.tag.markers["sensitive"]; ok {
+			args = append(args, "-"+f.name+"="+redacted)
+			return nil
+		}
+		if len(cur) == 0 {
+			cur = []string{values[1]}
+		}
+		for _, c := range cur {
+			args = append(args, "-"+f.name+"="+c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+// checkSeparator returns an error if one of the values cur of the
+// slice or map field f contains the separator given by its sep marker,
+// such a value being split into several elements when parsed again.
+func checkSeparator(f *taggedField, cur []string) error {
+	sep := f.tag.markers["sep"]
+	typ := f.Type
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if sep == "" || typ.Kind() != reflect.Slice && typ.Kind() != reflect.Map {
+		return nil
+	}
+	for _, c := range cur {
+		if strings.Contains(c, sep) {
+			return fieldError(InvalidDefault, f.path, f.name, "current value %q of flag %q contains the separator %q", c, f.name, sep)
+		}
+	}
+	return nil
+}
+
+// DumpValues writes to w the current value of the fields of the
+// struct contained in s, one "name = value" line per flag in the order
+// of the fields, the name being the full name of the flag, including
+// its prefix if any. The values are formatted by the String method of
+// the flag.Value of the flag, e.g. 10MB for a field with the bytesize
+// marker, a nil pointer field giving <nil>. The value of the fields
+// with the sensitive marker is replaced by "***". It lets a program
+// log its effective configuration, e.g. at startup.
+func DumpValues(s any, w io.Writer, opts ...Option) error {
+	v := reflect.Indirect(reflect.ValueOf(s))
+	if v.Kind() != reflect.Struct {
+		return errors.New("not a struct")
+	}
+	o := newOptions(opts)
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	defer forgetFlagSet(fs)
+	return visitFields(v.Type(), o, func(f *taggedField) error {
+		if !f.IsExported() {
+			return nil
+		}
+		value := redacted
+		if _, ok := f.tag.markers["sensitive"]; !ok {
+			fv, err := v.FieldByIndexErr(f.index)
+			if err != nil || fv.Kind() == reflect.Pointer && fv.IsNil() {
+				value = "<nil>"
+			} else {
+				ft := *f.tag
+				ft.deflt, ft.emptyDeflt = "", false
+				cf := *f
+				cf.tag = &ft
+				if err := addFlag(fs, &cf, reflect.Value{}, o); err != nil {
+					return err
+				}
+				fl := fs.Lookup(f.name)
+				for _, cur := range formatField(fv, f.tag) {
+					if err := fl.Value.Set(cur); err != nil {
+						return fieldError(InvalidDefault, f.path, f.name, "invalid current value %q for flag %q: %w", cur, f.name, err)
+					}
+				}
+				value = fl.Value.String()
+			}
+		}
+		_, err := fmt.Fprintf(w, "%s = %s\n", f.name, value)
+		return err
+	})
+}
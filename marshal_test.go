@@ -0,0 +1,43 @@
+package sflag
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMarshalArgsRoundTrip(t *testing.T) {
+	type config struct {
+		Tags  []string `flag:"tag,,tags|sep=,"`
+		Names []string `flag:"name,,names"`
+		Size  int64    `flag:"size,0,size|bytesize"`
+	}
+	s := config{Tags: []string{"a", "b"}, Names: []string{"x,y"}, Size: 2048}
+	args, err := MarshalArgs(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"-tag=a", "-tag=b", "-name=x,y", "-size=2KiB"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("got args %q, want %q", args, want)
+	}
+	var b bytes.Buffer
+	if err := DumpValues(&s, &b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "size = 2KiB\n") {
+		t.Errorf("DumpValues output doesn't contain the size 2KiB:\n%s", &b)
+	}
+	var got config
+	if err := setFromArgs(t, &got, args); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, s) {
+		t.Errorf("got %+v, want %+v", got, s)
+	}
+	// An element containing the separator can't be given back
+	s.Tags = []string{"a,b"}
+	if args, err := MarshalArgs(&s); err == nil {
+		t.Errorf("got args %q, want an error", args)
+	}
+}
@@ -0,0 +1,69 @@
+package sflag
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultFlagName returns the name of the flag derived from the field
+// f when the name is omitted from its tag. It is the name given by the
+// json tag of the field if any, otherwise the name of the field in
+// kebab case, e.g. the field MaxConns gives the flag "max-conns".
+func DefaultFlagName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name != "" && name != "-" {
+		return name
+	}
+	return KebabCase(f.Name)
+}
+
+// KebabCase converts name, a Go identifier, to kebab case, e.g.
+// HTTPPort becomes "http-port".
+func KebabCase(name string) string {
+	return splitWords(name, '-')
+}
+
+// SnakeCase converts name, a Go identifier, to snake case, e.g.
+// HTTPPort becomes "http_port".
+func SnakeCase(name string) string {
+	return splitWords(name, '_')
+}
+
+// CamelCase converts name, a Go identifier, to lower camel case, e.g.
+// HTTPPort becomes "httpPort".
+func CamelCase(name string) string {
+	words := strings.Split(splitWords(name, '-'), "-")
+	for i := 1; i < len(words); i++ {
+		r, size := utf8.DecodeRuneInString(words[i])
+		words[i] = string(unicode.ToUpper(r)) + words[i][size:]
+	}
+	return strings.Join(words, "")
+}
+
+// LowerCase converts name, a Go identifier, to lower case, e.g.
+// HTTPPort becomes "httpport".
+func LowerCase(name string) string {
+	return strings.ToLower(name)
+}
+
+// splitWords lowercases name, a Go identifier, inserting sep before
+// each word but the first. A word starts with an uppercase letter
+// following a lowercase letter or a digit, or with the last letter of
+// a sequence of uppercase letters followed by a lowercase letter.
+func splitWords(name string, sep rune) string {
+	runes := []rune(name)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prev := runes[i-1]
+			next := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && next {
+				b.WriteRune(sep)
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
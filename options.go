@@ -0,0 +1,236 @@
+package sflag
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// Option configures the way flags are derived from struct fields. The
+// same options must be given to the functions adding the flags and to
+// the functions setting the struct fields from the flags.
+type Option func(*options)
+
+type options struct {
+	tagKey       string
+	nameFunc     func(f reflect.StructField) string
+	nestedPrefix bool
+	nestedSep    string
+	// caseInsensitive is set by WithCaseInsensitiveNames
+	caseInsensitive bool
+	// untagged is set by WithUntaggedFields
+	untagged bool
+	// expandDefaults is set by WithExpandedDefaults
+	expandDefaults bool
+	// annotateHelp is set by WithAnnotatedHelp
+	annotateHelp bool
+	// alwaysApplyDefaults is set by WithAlwaysApplyDefaults
+	alwaysApplyDefaults bool
+	// mergeSlices is set by WithSliceMerge
+	mergeSlices bool
+	// usageHeader is set by WithUsageHeader
+	usageHeader string
+	// mapParsers maps the names of the map flags to the function
+	// parsing the values of their entries, see Binder.AddStructMap
+	mapParsers map[string]func(s string) (any, error)
+	// errorHandling is set by WithErrorHandling
+	errorHandling flag.ErrorHandling
+	// strictTags is set by WithStrictTags
+	strictTags bool
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		tagKey:    TagKey,
+		nameFunc:  DefaultFlagName,
+		nestedSep: ".",
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithTagKey sets the key used to retrieve informations about the
+// flag in the struct field tag. It defaults to TagKey.
+func WithTagKey(key string) Option {
+	return func(o *options) {
+		o.tagKey = key
+	}
+}
+
+// WithNameFunc sets the function deriving the name of a flag from its
+// struct field when the name is omitted from the field tag, including
+// the untagged fields turned into flags by WithUntaggedFields. It
+// defaults to DefaultFlagName. KebabCase, SnakeCase, CamelCase and
+// LowerCase implement the usual naming conventions, e.g.
+//
+//	sflag.WithNameFunc(func(f reflect.StructField) string {
+//		return sflag.SnakeCase(f.Name)
+//	})
+//
+// The derived names are checked for duplicates like the names given
+// by the tags.
+func WithNameFunc(fn func(f reflect.StructField) string) Option {
+	return func(o *options) {
+		o.nameFunc = fn
+	}
+}
+
+// WithNestedPrefix prefixes the name of the flags defined in nested
+// structs with the lowercased name of the field holding the nested
+// struct, e.g. the flag "port" of the field Server becomes
+// "server.port". The prefixes of multiple levels of nesting are
+// joined. The flags of embedded structs are not prefixed.
+func WithNestedPrefix() Option {
+	return func(o *options) {
+		o.nestedPrefix = true
+	}
+}
+
+// WithNestedSeparator sets the separator used between the prefix and
+// the name of the flags defined in nested structs, e.g. "-" for GNU
+// style names such as "server-port". It defaults to ".". It has no
+// effect without WithNestedPrefix. The separator is used at every
+// level of nesting and can't contain a "=" or white space, which
+// can't be part of a flag name given on the command line.
+func WithNestedSeparator(sep string) Option {
+	return func(o *options) {
+		o.nestedSep = sep
+	}
+}
+
+// checkNestedSep returns an error if the separator set by
+// WithNestedSeparator can't be used in the name of a flag.
+func (o *options) checkNestedSep() error {
+	if !o.nestedPrefix {
+		return nil
+	}
+	if strings.Contains(o.nestedSep, "=") || strings.IndexFunc(o.nestedSep, unicode.IsSpace) >= 0 {
+		return fmt.Errorf("invalid nested separator %q", o.nestedSep)
+	}
+	return nil
+}
+
+// WithCaseInsensitiveNames records the names of the flags so that
+// NormalizeArgs can rewrite the flag names of the command line
+// arguments to their canonical case before parsing, e.g. -Port
+// becomes -port. Adding two flags whose names only differ by their
+// case is an error.
+func WithCaseInsensitiveNames() Option {
+	return func(o *options) {
+		o.caseInsensitive = true
+	}
+}
+
+// WithUntaggedFields turns the exported fields without tag into flags
+// as if their tag was `flag:",,"`, the name of the flag being derived
+// from the field, see WithNameFunc. The fields whose type can't be
+// turned into a flag are skipped and the untagged struct fields are
+// still recursed into, unless their type is supported, e.g.
+// time.Time.
+func WithUntaggedFields() Option {
+	return func(o *options) {
+		o.untagged = true
+	}
+}
+
+// WithExpandedDefaults expands the references to environment
+// variables, given as ${VAR} or $VAR, in the default values of the
+// tags, e.g. `flag:"dir,${HOME}/.app,data directory"`. The undefined
+// variables expand to the empty string.
+func WithExpandedDefaults() Option {
+	return func(o *options) {
+		o.expandDefaults = true
+	}
+}
+
+// WithAnnotatedHelp appends to the help message of the flags the
+// constraints given by their markers, e.g. "(allowed: a|b|c)" for the
+// oneof marker or "(range: [1, 256])" for the min and max markers, so
+// that they are printed by fs.PrintDefaults.
+func WithAnnotatedHelp() Option {
+	return func(o *options) {
+		o.annotateHelp = true
+	}
+}
+
+// WithAlwaysApplyDefaults makes the functions setting the struct
+// fields from the flags assign the default value of the flags not
+// given on the command line to their field, even if the field holds a
+// non-zero value. By default, such a value is preserved, see
+// AddFlagsWithDefaults. The flags are then the only source of truth.
+func WithAlwaysApplyDefaults() Option {
+	return func(o *options) {
+		o.alwaysApplyDefaults = true
+	}
+}
+
+// WithSliceMerge makes the functions setting the struct fields from
+// the flags append the elements given on the command line to the
+// current elements of the slice fields instead of replacing them. The
+// entries of the map fields are likewise added to the current
+// entries, the command line winning for duplicate keys. It only
+// affects the slice and map fields, byte slices excepted.
+func WithSliceMerge() Option {
+	return func(o *options) {
+		o.mergeSlices = true
+	}
+}
+
+// WithUsageHeader sets fs.Usage, when the flags are added, to a
+// function printing header, e.g. a description of the program, to
+// fs.Output() followed by the flags organized in groups, see
+// PrintGroupedDefaults. Like the default fs.Usage, the function
+// doesn't exit: fs.Parse still exits or returns an error on parsing
+// errors and for -help according to its error handling mode.
+func WithUsageHeader(header string) Option {
+	return func(o *options) {
+		o.usageHeader = header
+	}
+}
+
+// WithErrorHandling sets the way the functions adding the flags and
+// setting the struct fields from the flags, i.e. AddFlagsWith,
+// AddFlagsWithDefaults, AddFlagsWithDefaultMap, AddFlagsAuto,
+// SetFromFlagsWith, SetFromFlagsContext, SetFromFlagsReport and
+// SetFromFlagsSubset, handle their errors, like the error handling of
+// a flag.FlagSet does for the parsing errors:
+//
+//   - flag.ContinueOnError, the default, returns the error
+//   - flag.PanicOnError panics with the error, like AddFlags and
+//     SetFromFlags do
+//   - flag.ExitOnError prints the error to the output of the
+//     flag.FlagSet and exits with status 2
+func WithErrorHandling(h flag.ErrorHandling) Option {
+	return func(o *options) {
+		o.errorHandling = h
+	}
+}
+
+// WithStrictTags makes the tags with a marker unknown to the package,
+// e.g. a misspelled marker such as |requird, invalid. By default, the
+// unknown markers are ignored for forward compatibility.
+func WithStrictTags() Option {
+	return func(o *options) {
+		o.strictTags = true
+	}
+}
+
+// handleError handles *errp, the error of a function given o and fs,
+// according to the error handling of o, see WithErrorHandling.
+func (o *options) handleError(fs *flag.FlagSet, errp *error) {
+	if *errp == nil {
+		return
+	}
+	switch o.errorHandling {
+	case flag.PanicOnError:
+		panic(*errp)
+	case flag.ExitOnError:
+		fmt.Fprintln(fs.Output(), *errp)
+		os.Exit(2)
+	}
+}
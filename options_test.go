@@ -0,0 +1,117 @@
+package sflag
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+)
+
+func TestAlwaysApplyDefaults(t *testing.T) {
+	type config struct {
+		Host string `flag:"host,localhost,host"`
+		Port int    `flag:"port,80,port"`
+	}
+	for _, tt := range []struct {
+		opts []Option
+		want config
+	}{
+		{want: config{Host: "example.com", Port: 8080}},
+		{opts: []Option{WithAlwaysApplyDefaults()}, want: config{Host: "localhost", Port: 8080}},
+	} {
+		c := config{Host: "example.com", Port: 443}
+		if err := setFromArgs(t, &c, []string{"-port", "8080"}, tt.opts...); err != nil {
+			t.Fatal(err)
+		}
+		if c != tt.want {
+			t.Errorf("got %+v, want %+v", c, tt.want)
+		}
+	}
+}
+
+func TestSliceMerge(t *testing.T) {
+	type config struct {
+		Tags   []string          `flag:"tag,,tags"`
+		Limits map[string]string `flag:"limit,,limits"`
+	}
+	args := []string{"-tag", "c", "-limit", "b=3", "-limit", "c=4"}
+	for _, tt := range []struct {
+		opts []Option
+		want config
+	}{
+		{
+			want: config{Tags: []string{"c"}, Limits: map[string]string{"b": "3", "c": "4"}},
+		},
+		{
+			opts: []Option{WithSliceMerge()},
+			want: config{Tags: []string{"a", "b", "c"}, Limits: map[string]string{"a": "1", "b": "3", "c": "4"}},
+		},
+	} {
+		c := config{Tags: []string{"a", "b"}, Limits: map[string]string{"a": "1", "b": "2"}}
+		if err := setFromArgs(t, &c, args, tt.opts...); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(c, tt.want) {
+			t.Errorf("got %+v, want %+v", c, tt.want)
+		}
+		// Without the flags, the fields are left untouched
+		c = config{Tags: []string{"a"}}
+		if err := setFromArgs(t, &c, nil, tt.opts...); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(c.Tags, []string{"a"}) {
+			t.Errorf("got Tags=%q without flag, want [a]", c.Tags)
+		}
+	}
+}
+
+func TestNestedSeparator(t *testing.T) {
+	type tls struct {
+		Cert string `flag:"cert,,certificate"`
+	}
+	type server struct {
+		Port int `flag:"port,80,port"`
+		TLS  tls `flag:"tls,,"`
+	}
+	type config struct {
+		Server server
+	}
+	for _, sep := range []string{".", "-", "_", ""} {
+		opts := []Option{WithNestedPrefix(), WithNestedSeparator(sep)}
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		var c config
+		if err := AddFlagsWith(fs, &c, opts...); err != nil {
+			t.Fatalf("separator %q: %v", sep, err)
+		}
+		var names []string
+		fs.VisitAll(func(fl *flag.Flag) {
+			names = append(names, fl.Name)
+		})
+		want := []string{"server" + sep + "port", "server" + sep + "tls" + sep + "cert"}
+		if !reflect.DeepEqual(names, want) {
+			t.Errorf("separator %q: got flags %q, want %q", sep, names, want)
+		}
+		if err := fs.Parse([]string{"-" + want[0], "8080", "-" + want[1], "a.pem"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := SetFromFlagsWith(&c, fs, opts...); err != nil {
+			t.Fatal(err)
+		}
+		if c.Server.Port != 8080 || c.Server.TLS.Cert != "a.pem" {
+			t.Errorf("separator %q: got %+v", sep, c)
+		}
+		indexes, err := FlagIndexes(&c, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := indexes[want[1]]; !reflect.DeepEqual(got, []int{0, 1, 0}) {
+			t.Errorf("separator %q: got index %v for %s, want [0 1 0]", sep, got, want[1])
+		}
+	}
+	for _, sep := range []string{"=", " ", "a\tb"} {
+		var c config
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		if err := AddFlagsWith(fs, &c, WithNestedPrefix(), WithNestedSeparator(sep)); err == nil {
+			t.Errorf("separator %q: got no error", sep)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package sflag
+
+import (
+	"flag"
+	"reflect"
+	"sync"
+)
+
+// argsTag is the tag of the field receiving the positional arguments
+// left after parsing, see TagKey.
+const argsTag = "@args"
+
+// argsCache caches the results of argsField, keyed like fieldCache.
+var argsCache sync.Map
+
+// argsResult is a result of argsField.
+type argsResult struct {
+	f   *taggedField
+	err error
+}
+
+// argsField returns the field of the struct type typ receiving the
+// positional arguments, or nil if there's none, from the cache if
+// possible. It's an error to have more than one such field or a field
+// whose type isn't a slice of strings.
+func argsField(typ reflect.Type, o *options) (*taggedField, error) {
+	key, ok := newFieldCacheKey(typ, o)
+	if ok {
+		if r, found := argsCache.Load(key); found {
+			return r.(argsResult).f, r.(argsResult).err
+		}
+	}
+	f, err := findArgsField(typ, o)
+	if ok {
+		argsCache.Store(key, argsResult{f, err})
+	}
+	return f, err
+}
+
+// findArgsField is like argsField but it always visits the fields of
+// typ.
+func findArgsField(typ reflect.Type, o *options) (*taggedField, error) {
+	var args *taggedField
+	var err error
+	fv := &fieldVisitor{
+		o:        o,
+		fn:       func(*taggedField) error { return nil },
+		visiting: make(map[reflect.Type]bool),
+		// The errors of the other fields are ignored
+		errs: []error{},
+		args: func(f *taggedField) error {
+			switch ftyp := f.Type; {
+			case err != nil:
+			case args != nil:
+				err = fieldError(InvalidTag, f.path, "", "positional arguments already bound to field %s", args.path)
+			case ftyp.Kind() != reflect.Slice || ftyp.Elem().Kind() != reflect.String:
+				err = fieldError(UnsupportedType, f.path, "", "positional arguments can't be bound to field of type %q", ftyp)
+			default:
+				args = f
+			}
+			return nil
+		},
+	}
+	fv.visit(typ, nil, "", "")
+	if err != nil {
+		return nil, err
+	}
+	return args, nil
+}
+
+// setArgs assigns the positional arguments left in fs after parsing
+// to the field of the struct v tagged with argsTag, if any. The field
+// is left untouched if there are no positional arguments.
+func setArgs(v reflect.Value, fs *flag.FlagSet, o *options) error {
+	f, err := argsField(v.Type(), o)
+	if err != nil {
+		return err
+	}
+	assignArgs(v, fs, f)
+	return nil
+}
+
+// assignArgs assigns the positional arguments left in fs after parsing
+// to the field f of the struct v, if f is not nil and there are
+// positional arguments.
+func assignArgs(v reflect.Value, fs *flag.FlagSet, f *taggedField) {
+	if f == nil || fs.NArg() == 0 {
+		return
+	}
+	fiv := fieldByIndex(v, f.index)
+	args := reflect.MakeSlice(fiv.Type(), fs.NArg(), fs.NArg())
+	for i, arg := range fs.Args() {
+		args.Index(i).SetString(arg)
+	}
+	fiv.Set(args)
+}
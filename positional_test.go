@@ -0,0 +1,55 @@
+package sflag
+
+import (
+	"flag"
+	"reflect"
+	"testing"
+)
+
+type argsConfig struct {
+	Verbose bool     `flag:"v,,verbose"`
+	Files   []string `flag:"@args"`
+}
+
+func TestArgs(t *testing.T) {
+	var c argsConfig
+	if err := setFromArgs(t, &c, []string{"-v", "a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Verbose || !reflect.DeepEqual(c.Files, []string{"a", "b"}) {
+		t.Errorf("got %+v", c)
+	}
+	b := NewBinder()
+	if err := b.Add(&c); err != nil {
+		t.Fatal(err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := b.AddFlags(fs); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse([]string{"c"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Apply(fs); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.Files, []string{"c"}) {
+		t.Errorf("got %q, want [c]", c.Files)
+	}
+}
+
+func TestArgsInvalid(t *testing.T) {
+	var s struct {
+		A []string `flag:"@args"`
+		B []string `flag:"@args"`
+	}
+	if err := NewBinder().Add(&s); err == nil {
+		t.Error("got no error for two @args fields")
+	}
+	var s2 struct {
+		A []int `flag:"@args"`
+	}
+	if err := Validate(&s2); err == nil {
+		t.Error("got no error for an @args field of type []int")
+	}
+}
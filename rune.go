@@ -0,0 +1,53 @@
+package sflag
+
+import (
+	"fmt"
+	"reflect"
+	"unicode/utf8"
+)
+
+// parseRune parses s as a single UTF-8 encoded character.
+func parseRune(s string) (rune, error) {
+	r, size := utf8.DecodeRuneInString(s)
+	if r == utf8.RuneError && size <= 1 {
+		return 0, fmt.Errorf("invalid character %q", s)
+	}
+	if size != len(s) {
+		return 0, fmt.Errorf("%q is not a single character", s)
+	}
+	return r, nil
+}
+
+// runeValue is a flag.Value parsing a single character using
+// parseRune. typ is the int32 type of the value returned by Get.
+type runeValue struct {
+	r   rune
+	typ reflect.Type
+}
+
+func newRuneValue(typ reflect.Type) (*runeValue, error) {
+	if typ.Kind() != reflect.Int32 {
+		return nil, fmt.Errorf("rune flags require an int32 type")
+	}
+	return &runeValue{typ: typ}, nil
+}
+
+func (v *runeValue) Set(s string) error {
+	r, err := parseRune(s)
+	if err != nil {
+		return err
+	}
+	v.r = r
+	return nil
+}
+
+func (v *runeValue) String() string {
+	if v == nil || v.r == 0 {
+		return ""
+	}
+	return string(v.r)
+}
+
+func (v *runeValue) Get() any {
+	return reflect.ValueOf(v.r).Convert(v.typ).Interface()
+}
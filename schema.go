@@ -0,0 +1,148 @@
+package sflag
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// FieldSpec describes a flag bound to an entry of a map rather than
+// to a struct field, see AddFlagsFromSchema.
+type FieldSpec struct {
+	// Name is the name of the flag and the key of the map entry
+	Name string
+	// Type is the type of the map entry, any type supported for a
+	// struct field being supported
+	Type    reflect.Type
+	Default string
+	Help    string
+	// Markers holds the markers of the flag as they would be given
+	// in a struct field tag, an empty value standing for a bare
+	// marker
+	Markers map[string]string
+}
+
+// AddFlagsFromSchema adds to fs the flags described by schema. The
+// flags behave as if they were derived from the fields of a struct,
+// each FieldSpec giving the type and the tag of a field. Their values
+// are retrieved by SetFromMap.
+func AddFlagsFromSchema(fs *flag.FlagSet, schema []FieldSpec, opts ...Option) error {
+	o := newOptions(opts)
+	typ, err := schemaType(schema, o)
+	if err != nil {
+		return err
+	}
+	if err := addFlags(fs, typ, o); err != nil {
+		return schemaError(err, schema)
+	}
+	setUsage(fs, o)
+	updateFlagSetInfo(fs, func(info *flagSetInfo) {
+		info.schemas = append(info.schemas, boundSchema{typ, schema, o})
+	})
+	return nil
+}
+
+// SetFromMap sets the entries of m, keyed by the names of the flags,
+// with the values of the flags added to fs by AddFlagsFromSchema. The
+// entries of the pointer types are only set if the flag is given on
+// the command line or has a default value.
+func SetFromMap(m map[string]any, fs *flag.FlagSet) error {
+	if !fs.Parsed() {
+		return errors.New("flag not parsed")
+	}
+	var schemas []boundSchema
+	readFlagSetInfo(fs, func(info *flagSetInfo) {
+		if info != nil {
+			schemas = append(schemas, info.schemas...)
+		}
+	})
+	for _, bs := range schemas {
+		fields, err := getFlagFields(bs.typ, bs.o)
+		if err != nil {
+			return err
+		}
+		v := reflect.New(bs.typ).Elem()
+		if err := setFields(context.Background(), v, fs, fields, bs.o); err != nil {
+			return schemaError(err, bs.schema)
+		}
+		for i, spec := range bs.schema {
+			fv := v.Field(i)
+			if fv.Kind() == reflect.Pointer && fv.IsNil() {
+				continue
+			}
+			m[spec.Name] = fv.Interface()
+		}
+	}
+	return nil
+}
+
+// boundSchema holds a schema added to a flag.FlagSet, typ being the
+// struct type derived from it and o the options used to add it.
+type boundSchema struct {
+	typ    reflect.Type
+	schema []FieldSpec
+	o      *options
+}
+
+// schemaType returns a struct type whose fields are described by
+// schema, the i-th field corresponding to the i-th FieldSpec.
+func schemaType(schema []FieldSpec, o *options) (reflect.Type, error) {
+	fields := make([]reflect.StructField, len(schema))
+	for i, spec := range schema {
+		if spec.Name == "" {
+			return nil, fmt.Errorf("field spec %d: empty name", i)
+		}
+		if spec.Type == nil {
+			return nil, fmt.Errorf("field spec %q: no type", spec.Name)
+		}
+		tag := escapeTag(spec.Name) + "," + escapeTag(spec.Default) + "," + escapeTag(spec.Help)
+		keys := make([]string, 0, len(spec.Markers))
+		for key := range spec.Markers {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			tag += "|" + escapeTag(key)
+			if value := spec.Markers[key]; value != "" {
+				tag += "=" + escapeTag(value)
+			}
+		}
+		fields[i] = reflect.StructField{
+			Name: fmt.Sprintf("F%d", i),
+			Type: spec.Type,
+			Tag:  reflect.StructTag(fmt.Sprintf("%s:%q", o.tagKey, tag)),
+		}
+	}
+	return reflect.StructOf(fields), nil
+}
+
+// schemaError replaces in err, if it is a *FlagError, the name of the
+// field of the struct type derived from schema by the name of the
+// corresponding FieldSpec.
+func schemaError(err error, schema []FieldSpec) error {
+	var fe *FlagError
+	if errors.As(err, &fe) {
+		var i int
+		if _, e := fmt.Sscanf(fe.Field, "F%d", &i); e == nil && i < len(schema) {
+			fe.Field = schema[i].Name
+		}
+	}
+	return err
+}
+
+// escapeTag escapes the characters of s having a special meaning in a
+// tag, the reverse of unescapeTag.
+func escapeTag(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(`\,|;`, s[i]) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
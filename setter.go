@@ -0,0 +1,78 @@
+package sflag
+
+import (
+	"fmt"
+	"reflect"
+)
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// hasSetter reports whether the tag value tag has a setter marker. It
+// lets an unexported field be bound to a flag through its setter.
+func hasSetter(tag string) bool {
+	if tag == "" || tag == "-" {
+		return false
+	}
+	ft, err := parseTag(tag)
+	return err == nil && ft.markers["setter"] != ""
+}
+
+// checkSetter checks that the pointer to the struct type typ has a
+// method name taking a single parameter to which a value of the type
+// of the field fi can be assigned or converted, and returning either
+// nothing or an error.
+func checkSetter(typ reflect.Type, fi reflect.StructField, name string) error {
+	m, ok := reflect.PointerTo(typ).MethodByName(name)
+	if !ok {
+		return fmt.Errorf("no setter method %q for type %q", name, typ)
+	}
+	mt := m.Type
+	if mt.NumIn() != 2 || mt.NumOut() > 1 || mt.NumOut() == 1 && mt.Out(0) != errorType {
+		return fmt.Errorf("invalid signature %q of setter method %q, expected a single parameter and an optional error result", mt, name)
+	}
+	if p := mt.In(1); !fi.Type.AssignableTo(p) && !canConvert(fi.Type, p) {
+		return fmt.Errorf("invalid parameter type %q of setter method %q for field of type %q", p, name, fi.Type)
+	}
+	return nil
+}
+
+// setterTarget returns the value to which the value of the flag of
+// the field f, whose value is fv, is assigned: fv itself or, if f has
+// a setter method, a new value then given to the setter by callSetter.
+func setterTarget(f *taggedField, fv reflect.Value) reflect.Value {
+	if f.tag.markers["setter"] == "" {
+		return fv
+	}
+	return reflect.New(fv.Type()).Elem()
+}
+
+// callSetter calls the setter method of the field f of the struct v,
+// if any, with x, the value of the flag name.
+func callSetter(v reflect.Value, f *taggedField, name string, x reflect.Value) error {
+	setter := f.tag.markers["setter"]
+	if setter == "" {
+		return nil
+	}
+	parent := v
+	if len(f.index) > 1 {
+		parent = fieldByIndex(v, f.index[:len(f.index)-1])
+		if parent.Kind() == reflect.Pointer {
+			if parent.IsNil() {
+				parent.Set(reflect.New(parent.Type().Elem()))
+			}
+			parent = parent.Elem()
+		}
+	}
+	m := parent.Addr().MethodByName(setter)
+	if p := m.Type().In(0); x.Type() != p {
+		if overflows(x, p) {
+			return fieldError(ConversionFailed, f.path, name, "flag %q: value %v overflows parameter of type %q", name, x, p)
+		}
+		x = x.Convert(p)
+	}
+	out := m.Call([]reflect.Value{x})
+	if len(out) == 1 && !out[0].IsNil() {
+		return fieldError(ConversionFailed, f.path, name, "flag %q: %w", name, out[0].Interface().(error))
+	}
+	return nil
+}
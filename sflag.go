@@ -1,135 +1,1153 @@
 package sflag
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"os"
 	"reflect"
+	"sort"
 	"strings"
 	"time"
 )
 
-// TagKey is the key used to retrieve informations about the flag in
-// the struct field tag. The value associated with the tag key must be
-// a comma separated list of three items:
-//   - the name of the flag
-//   - the default value for the flag
-//   - the help message for the flag
+// TagKey is the default key used to retrieve informations about the
+// flag in the struct field tag, see WithTagKey. The syntax of the
+// value associated with the key is described in the package
+// documentation.
 const TagKey = "flag"
 
-func parseTag(v string) (name string, deflt string, help string) {
-	parts := strings.SplitN(v, ",", 3)
+// flagTag holds the informations contained in a struct field tag.
+type flagTag struct {
+	name  string
+	deflt string
+	// emptyDeflt is set if the default value is explicitly empty
+	emptyDeflt bool
+	help       string
+	markers    map[string]string
+}
+
+// knownMarkers holds the keys of the markers recognized by the
+// package, see the package documentation.
+var knownMarkers = map[string]bool{
+	"alias":       true,
+	"base":        true,
+	"binary":      true,
+	"bytesize":    true,
+	"count":       true,
+	"defaultfrom": true,
+	"deprecated":  true,
+	"duration":    true,
+	"encoding":    true,
+	"env":         true,
+	"exclude":     true,
+	"fromfile":    true,
+	"group":       true,
+	"hidden":      true,
+	"ignorecase":  true,
+	"include":     true,
+	"layout":      true,
+	"max":         true,
+	"min":         true,
+	"mutex":       true,
+	"negatable":   true,
+	"oneof":       true,
+	"oneofci":     true,
+	"required":    true,
+	"rune":        true,
+	"sensitive":   true,
+	"sep":         true,
+	"setter":      true,
+	"shellwords":  true,
+	"unit":        true,
+}
+
+// checkMarkers returns an error if the tag ft has a marker unknown to
+// the package, e.g. a misspelled one.
+func checkMarkers(ft *flagTag) error {
+	var unknown []string
+	for key := range ft.markers {
+		if !knownMarkers[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	return fmt.Errorf("unknown marker %q", unknown[0])
+}
+
+func parseTag(v string) (*flagTag, error) {
+	first := splitTag(v, ',', 2)[0]
+	first = splitTag(first, ';', 2)[0]
+	if strings.Contains(first, "=") {
+		return parseKeyedTag(v)
+	}
+	parts := splitTag(v, ',', 3)
 	if len(parts) != 3 {
-		panic(fmt.Sprintf("invalid tag value %q", v))
+		return nil, fmt.Errorf("invalid tag value %q", v)
+	}
+	t := &flagTag{
+		name:    unescapeTag(parts[0]),
+		deflt:   unescapeTag(parts[1]),
+		markers: make(map[string]string),
 	}
-	name, deflt, help = parts[0], parts[1], parts[2]
-	return
+	items := splitTag(parts[2], '|', 0)
+	t.help = unescapeTag(items[0])
+	for _, m := range items[1:] {
+		key, value, _ := strings.Cut(m, "=")
+		t.markers[unescapeTag(key)] = unescapeTag(value)
+	}
+	return t, nil
+}
+
+// parseKeyedTag parses a tag made of a semicolon separated list of
+// key=value pairs. The name, default and usage keys hold respectively
+// the name, the default value and the help message of the flag, the
+// other keys are markers.
+func parseKeyedTag(v string) (*flagTag, error) {
+	t := &flagTag{
+		markers: make(map[string]string),
+	}
+	for _, item := range splitTag(v, ';', 0) {
+		key, value, _ := strings.Cut(item, "=")
+		key, value = unescapeTag(key), unescapeTag(value)
+		switch key {
+		case "name":
+			t.name = value
+		case "default":
+			t.deflt = value
+			t.emptyDeflt = value == ""
+		case "usage":
+			t.help = value
+		case "":
+			return nil, fmt.Errorf("invalid tag value %q", v)
+		default:
+			t.markers[key] = value
+		}
+	}
+	return t, nil
+}
+
+// splitTag splits s around the occurrences of sep not escaped by a
+// backslash. If n > 0, at most n parts are returned. The escape
+// sequences are left untouched.
+func splitTag(s string, sep byte, n int) []string {
+	var parts []string
+	start := 0
+	for i := 0; i < len(s) && (n <= 0 || len(parts) < n-1); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unescapeTag replaces the escape sequences \\, \,, \| and \; of s with
+// the escaped character.
+func unescapeTag(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte(`\,|;`, s[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func fieldPath(parent string, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
 }
 
 // AddFlags adds flags to fs according to the tags of the struct
-// contained in s.
+// contained in s. It panics if an error occurs, see AddFlagsErr.
 func AddFlags(fs *flag.FlagSet, s any) {
+	if err := AddFlagsErr(fs, s); err != nil {
+		panic(err)
+	}
+}
+
+// AddFlagsErr is like AddFlags but it returns an error instead of
+// panicking if s is not a struct or if one of its fields can't be
+// turned into a flag. The error identifies the offending field.
+func AddFlagsErr(fs *flag.FlagSet, s any) error {
+	return AddFlagsWith(fs, s)
+}
+
+// AddFlagsWith is like AddFlagsErr but the way the flags are derived
+// from the struct fields can be customized using opts.
+func AddFlagsWith(fs *flag.FlagSet, s any, opts ...Option) (err error) {
+	o := newOptions(opts)
+	defer o.handleError(fs, &err)
+	v := reflect.Indirect(reflect.ValueOf(s))
+	if v.Kind() != reflect.Struct {
+		return errors.New("not a struct")
+	}
+	err = visitFields(v.Type(), o, func(f *taggedField) error {
+		fv, _ := v.FieldByIndexErr(f.index)
+		return addFlag(fs, f, fv, o)
+	})
+	if err != nil {
+		return err
+	}
+	setUsage(fs, o)
+	return nil
+}
+
+// AddFlagsWithDefaults is like AddFlagsWith but the current value of
+// the fields of the struct contained in s, if not the zero value, is
+// used as the default value of the corresponding flags instead of the
+// default value given in the tag. It lets the help message reflect
+// the effective configuration, e.g. the configuration read from a
+// file, SetFromFlags preserving the non-zero values of the fields
+// unless the flags are explicitly set. The value of an environment
+// variable given by the env marker still takes precedence.
+func AddFlagsWithDefaults(fs *flag.FlagSet, s any, opts ...Option) (err error) {
+	o := newOptions(opts)
+	defer o.handleError(fs, &err)
+	v := reflect.Indirect(reflect.ValueOf(s))
+	if v.Kind() != reflect.Struct {
+		return errors.New("not a struct")
+	}
+	err = visitFields(v.Type(), o, func(f *taggedField) error {
+		fv, err := v.FieldByIndexErr(f.index)
+		if err != nil || !f.IsExported() || fv.IsZero() {
+			return addFlag(fs, f, fv, o)
+		}
+		if env := f.tag.markers["env"]; env != "" && os.Getenv(env) != "" {
+			return addFlag(fs, f, fv, o)
+		}
+		// The current value of the field replaces the default
+		// value given in the tag
+		ft := *f.tag
+		ft.deflt, ft.emptyDeflt = "", false
+		cf := *f
+		cf.tag = &ft
+		if err := addFlag(fs, &cf, fv, o); err != nil {
+			return err
+		}
+		fl := fs.Lookup(f.name)
+		if sharedValue(f, fv) != nil {
+			// The flag already holds the current value
+			fl.DefValue = fl.Value.String()
+		} else {
+			for _, cur := range formatField(fv, f.tag) {
+				if err := setDefault(fl, f, cur); err != nil {
+					return fieldError(InvalidDefault, f.path, f.name, "invalid current value %q for flag %q: %w", cur, f.name, err)
+				}
+			}
+		}
+		for _, alias := range f.aliases {
+			fs.Lookup(alias).DefValue = fl.DefValue
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	setUsage(fs, o)
+	return nil
+}
+
+// AddFlagsWithDefaultMap is like AddFlagsWith but the values of
+// defaults, keyed by the name of a flag or one of its aliases,
+// replace the default value given in the tag of the corresponding
+// fields. The value of an environment variable given by the env
+// marker still takes precedence. A key that doesn't correspond to any
+// flag is an error.
+func AddFlagsWithDefaultMap(fs *flag.FlagSet, s any, defaults map[string]string, opts ...Option) (err error) {
+	o := newOptions(opts)
+	defer o.handleError(fs, &err)
 	v := reflect.Indirect(reflect.ValueOf(s))
 	if v.Kind() != reflect.Struct {
-		panic("not a struct")
+		return errors.New("not a struct")
 	}
-	addFlags(fs, &v)
+	fields, err := getFlagFields(v.Type(), o)
+	if err != nil {
+		return err
+	}
+	overrides := make(map[string]string, len(defaults))
+	for name, deflt := range defaults {
+		f := fields[name]
+		if f == nil {
+			return fmt.Errorf("unknown flag %q", name)
+		}
+		if _, ok := overrides[f.name]; ok {
+			return fmt.Errorf("multiple default values for flag %q", f.name)
+		}
+		overrides[f.name] = deflt
+	}
+	err = visitFields(v.Type(), o, func(f *taggedField) error {
+		fv, _ := v.FieldByIndexErr(f.index)
+		deflt, ok := overrides[f.name]
+		if !ok {
+			return addFlag(fs, f, fv, o)
+		}
+		ft := *f.tag
+		ft.deflt, ft.emptyDeflt = deflt, false
+		cf := *f
+		cf.tag = &ft
+		return addFlag(fs, &cf, fv, o)
+	})
+	if err != nil {
+		return err
+	}
+	setUsage(fs, o)
+	return nil
 }
 
-func addFlags(fs *flag.FlagSet, v *reflect.Value) {
-	fields := reflect.VisibleFields(v.Type())
-	for _, fi := range fields {
-		if fi.Anonymous || !fi.IsExported() {
+// AddFlagsAuto is like AddFlagsWithDefaults but the option
+// WithUntaggedFields is implied: every exported field of a supported
+// type is turned into a flag, even without tag. It is meant for quick
+// tools where tagging every field is a burden. The mode is recorded
+// for fs, so that the functions setting the struct fields from the
+// flags of fs, and checking them, imply WithUntaggedFields too.
+func AddFlagsAuto(fs *flag.FlagSet, s any, opts ...Option) error {
+	if err := AddFlagsWithDefaults(fs, s, append(opts, WithUntaggedFields())...); err != nil {
+		return err
+	}
+	updateFlagSetInfo(fs, func(info *flagSetInfo) {
+		info.untagged = true
+	})
+	return nil
+}
+
+// taggedField describes a struct field carrying a flag tag.
+type taggedField struct {
+	reflect.StructField
+	// index is the index sequence of the field relative to the
+	// root struct
+	index []int
+	// path is the dot separated list of the names of the fields
+	// leading to the field from the root struct
+	path string
+	// name is the name of the flag, including its prefix if any
+	name string
+	// aliases are the alternative names of the flag, including
+	// their prefix if any
+	aliases []string
+	// negated is the name of the flag negating a boolean flag,
+	// including its prefix if any
+	negated string
+	tag     *flagTag
+}
+
+// names returns the name of the flag followed by its aliases and by
+// the name of its negated flag if any.
+func (f *taggedField) names() []string {
+	names := append([]string{f.name}, f.aliases...)
+	if f.negated != "" {
+		names = append(names, f.negated)
+	}
+	return names
+}
+
+// visitFields calls fn for each exported field of the struct type typ
+// carrying a flag tag. It recurses into the struct and pointer to
+// struct fields without tag, a struct type being visited only once
+// per nesting path to avoid infinite recursion. The fields of the
+// embedded structs without tag are visited as if they were fields of
+// the embedding struct. The fields are retrieved from the cache of
+// the struct types if possible, fn being called only if all the tags
+// are valid.
+func visitFields(typ reflect.Type, o *options, fn func(f *taggedField) error) error {
+	fields, err := cachedFields(typ, o)
+	if err != nil {
+		return err
+	}
+	for _, f := range fields {
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// visitAllFields is like visitFields but it doesn't stop at the first
+// error, the errors returned by fn or raised by the visit of the
+// fields being joined.
+func visitAllFields(typ reflect.Type, o *options, fn func(f *taggedField) error) error {
+	if err := o.checkNestedSep(); err != nil {
+		return err
+	}
+	fv := &fieldVisitor{
+		o:        o,
+		fn:       fn,
+		visiting: make(map[reflect.Type]bool),
+		errs:     []error{},
+	}
+	fv.visit(typ, nil, "", "")
+	return errors.Join(fv.errs...)
+}
+
+type fieldVisitor struct {
+	o  *options
+	fn func(f *taggedField) error
+	// visiting holds the struct types being visited
+	visiting map[reflect.Type]bool
+	// filters holds the filters of the tagged nested structs being
+	// visited
+	filters []*nameFilter
+	// errs holds the errors of the visit if it must not stop at the
+	// first error
+	errs []error
+	// args, if not nil, is called for the field receiving the
+	// positional arguments, see argsTag
+	args func(f *taggedField) error
+}
+
+// fail returns err if the visit must stop at the first error,
+// otherwise it records err and returns nil.
+func (fv *fieldVisitor) fail(err error) error {
+	if fv.errs == nil {
+		return err
+	}
+	fv.errs = append(fv.errs, err)
+	return nil
+}
+
+func (fv *fieldVisitor) visit(typ reflect.Type, pindex []int, parent string, prefix string) error {
+	fv.visiting[typ] = true
+	defer delete(fv.visiting, typ)
+	for i := 0; i < typ.NumField(); i++ {
+		fi := typ.Field(i)
+		path := fieldPath(parent, fi.Name)
+		index := make([]int, len(pindex)+1)
+		copy(index, pindex)
+		index[len(pindex)] = i
+		tag := fi.Tag.Get(fv.o.tagKey)
+		if tag == "-" {
 			continue
 		}
-		typ := fi.Type
-		kind := typ.Kind()
-		if kind == reflect.Pointer {
-			typ = fi.Type.Elem()
-			kind = typ.Kind()
+		if tag == argsTag {
+			if fi.IsExported() && fv.args != nil {
+				f := &taggedField{StructField: fi, index: index, path: path}
+				if err := fv.args(f); err != nil {
+					if err := fv.fail(err); err != nil {
+						return err
+					}
+				}
+			}
+			continue
 		}
-		tag := fi.Tag.Get(TagKey)
-		if tag == "" {
-			if kind == reflect.Struct {
-				fiv := v.FieldByIndex(fi.Index)
-				addFlags(fs, &fiv)
+		if fi.Anonymous && tag == "" {
+			// The flags of an embedded struct are promoted to
+			// the embedding struct. An unexported embedded
+			// pointer can't be allocated and is skipped.
+			ftyp := fi.Type
+			if ftyp.Kind() == reflect.Pointer {
+				if !fi.IsExported() {
+					continue
+				}
+				ftyp = ftyp.Elem()
+			}
+			if ftyp.Kind() == reflect.Struct && !fv.visiting[ftyp] {
+				if err := fv.visit(ftyp, index, path, prefix); err != nil {
+					return err
+				}
 			}
 			continue
 		}
-		name, deflt, help := parseTag(tag)
-		if fl := fs.Lookup(name); fl != nil {
-			panic(fmt.Sprintf("flag %q already defined", name))
+		if !fi.IsExported() && !hasSetter(tag) {
+			continue
 		}
-		if i := reflect.TypeOf((*flag.Value)(nil)).Elem(); reflect.PointerTo(typ).Implements(i) {
-			pv := reflect.New(typ)
-			fs.Var(pv.Interface().(flag.Value), name, help)
+		var ft *flagTag
+		if tag == "" {
+			ftyp := fi.Type
+			if ftyp.Kind() == reflect.Pointer {
+				ftyp = ftyp.Elem()
+			}
+			if ftyp.Kind() == reflect.Struct && !(fv.o.untagged && isValueType(ftyp)) {
+				if fv.visiting[ftyp] {
+					continue
+				}
+				pfx := prefix
+				if fv.o.nestedPrefix {
+					pfx += strings.ToLower(fi.Name) + fv.o.nestedSep
+				}
+				if err := fv.visit(ftyp, index, path, pfx); err != nil {
+					return err
+				}
+				continue
+			}
+			if !fv.o.untagged || !isSupportedType(fi.Type) {
+				continue
+			}
+			ft = &flagTag{markers: make(map[string]string)}
 		} else {
-			switch kind {
-			case reflect.Bool:
-				fs.Bool(name, false, help)
-			case reflect.Int:
-				fs.Int(name, 0, help)
-			case reflect.Uint:
-				fs.Uint(name, 0, help)
-			case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-				var d time.Duration
-				if typ == reflect.TypeOf(d) {
-					fs.Duration(name, d, help)
-				} else {
-					fs.Int64(name, 0, help)
-				}
-			case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-				fs.Uint64(name, 0, help)
-			case reflect.Float32, reflect.Float64:
-				fs.Float64(name, 0.0, help)
-			case reflect.String:
-				fs.String(name, "", help)
+			var err error
+			if ft, err = parseTag(tag); err != nil {
+				if err := fv.fail(fieldError(InvalidTag, path, "", "%w", err)); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if ft.name == "" {
+			ft.name = fv.o.nameFunc(fi)
+			if ft.name == "" {
+				if err := fv.fail(fieldError(InvalidTag, path, "", "empty flag name")); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if fv.o.strictTags {
+			if err := checkMarkers(ft); err != nil {
+				if err := fv.fail(fieldError(InvalidTag, path, "", "%w", err)); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if ftyp := fi.Type; ftyp.Kind() == reflect.Struct || ftyp.Kind() == reflect.Pointer && ftyp.Elem().Kind() == reflect.Struct {
+			if ftyp.Kind() == reflect.Pointer {
+				ftyp = ftyp.Elem()
+			}
+			if _, binary := ft.markers["binary"]; !binary && lookupType(fi.Type) == nil && !isValueType(ftyp) {
+				if err := fv.visitNamespace(ftyp, index, path, prefix, ft); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !fv.allowed(prefix + ft.name) {
+			continue
+		}
+		if name := ft.markers["setter"]; name != "" {
+			if err := checkSetter(typ, fi, name); err != nil {
+				if err := fv.fail(fieldError(InvalidTag, path, "", "%w", err)); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		var aliases []string
+		if a := ft.markers["alias"]; a != "" {
+			for _, alias := range strings.Split(a, ",") {
+				aliases = append(aliases, prefix+alias)
+			}
+		}
+		var negated string
+		if _, ok := ft.markers["negatable"]; ok {
+			negated = prefix + "no-" + ft.name
+		}
+		f := &taggedField{
+			StructField: fi,
+			index:       index,
+			path:        path,
+			name:        prefix + ft.name,
+			aliases:     aliases,
+			negated:     negated,
+			tag:         ft,
+		}
+		if err := fv.fn(f); err != nil {
+			if err := fv.fail(err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// nameFilter restricts the flags defined in a nested struct to the
+// flags whose name, stripped of prefix, is in include, if not empty,
+// and not in exclude.
+type nameFilter struct {
+	prefix  string
+	include map[string]bool
+	exclude map[string]bool
+}
+
+// visitNamespace visits the nested struct type typ of a tagged field
+// of tag ft. The name of the tag prefixes the name of the flags of
+// the nested struct if the nested prefixes are enabled and the
+// include and exclude markers of the tag restrict its flags.
+func (fv *fieldVisitor) visitNamespace(typ reflect.Type, index []int, path string, prefix string, ft *flagTag) error {
+	if fv.visiting[typ] {
+		return nil
+	}
+	if fv.o.nestedPrefix {
+		prefix += ft.name + fv.o.nestedSep
+	}
+	nf := &nameFilter{
+		prefix:  prefix,
+		include: make(map[string]bool),
+		exclude: make(map[string]bool),
+	}
+	if include := ft.markers["include"]; include != "" {
+		for _, name := range strings.Split(include, ",") {
+			nf.include[name] = true
+		}
+	}
+	if exclude := ft.markers["exclude"]; exclude != "" {
+		for _, name := range strings.Split(exclude, ",") {
+			nf.exclude[name] = true
+		}
+	}
+	fv.filters = append(fv.filters, nf)
+	defer func() {
+		fv.filters = fv.filters[:len(fv.filters)-1]
+	}()
+	return fv.visit(typ, index, path, prefix)
+}
+
+// allowed reports whether the flag name passes the filters of the
+// nested structs being visited.
+func (fv *fieldVisitor) allowed(name string) bool {
+	for _, nf := range fv.filters {
+		rel := strings.TrimPrefix(name, nf.prefix)
+		if len(nf.include) > 0 && !nf.include[rel] || nf.exclude[rel] {
+			return false
+		}
+	}
+	return true
+}
+
+// fieldByIndex returns the nested field of the struct v corresponding
+// to index, allocating the nil pointers to struct on the way.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+func addFlags(fs *flag.FlagSet, typ reflect.Type, o *options) error {
+	return visitFields(typ, o, func(f *taggedField) error {
+		return addFlag(fs, f, reflect.Value{}, o)
+	})
+}
+
+// sharedValue returns the flag.Value held by fv, the value of the
+// field f, if f is a non-nil pointer implementing flag.Value. The flag
+// derived from f then shares this value instead of allocating a new
+// one.
+func sharedValue(f *taggedField, fv reflect.Value) flag.Value {
+	if lookupType(f.Type) != nil || !fv.IsValid() || fv.Kind() != reflect.Pointer || fv.IsNil() {
+		return nil
+	}
+	val, _ := fv.Interface().(flag.Value)
+	return val
+}
+
+// addFlag adds to fs the flag derived from the field f, fv being the
+// value of the field if any.
+func addFlag(fs *flag.FlagSet, f *taggedField, fv reflect.Value, o *options) error {
+	path, ft := f.path, f.tag
+	if !f.IsExported() {
+		// The value of an unexported field, only set through its
+		// setter method, can't be used
+		fv = reflect.Value{}
+	}
+	typ := f.Type
+	kind := typ.Kind()
+	if kind == reflect.Pointer {
+		typ = typ.Elem()
+		kind = typ.Kind()
+	}
+	name, deflt, help := f.name, ft.deflt, ft.help
+	if o.expandDefaults {
+		deflt = os.ExpandEnv(deflt)
+	}
+	deflts, err := tagDefaults(deflt, f.Type, ft)
+	if err != nil {
+		return fieldError(InvalidDefault, path, name, "invalid default value %q for flag %q: %w", deflt, name, err)
+	}
+	if o.annotateHelp {
+		help = annotateHelp(help, typ, ft)
+	}
+	if fl := fs.Lookup(name); fl != nil {
+		return fieldError(DuplicateFlag, path, name, "flag %q already defined", name)
+	}
+	i := flagValueType
+	if fn := lookupType(f.Type); fn != nil {
+		// The value returned by a registered handler already
+		// holds the default value
+		fs.Var(fn(strings.Join(deflts, ","), help), name, help)
+		deflts = nil
+	} else if sv := sharedValue(f, fv); sv != nil {
+		fs.Var(sv, name, help)
+	} else if enc, ok := ft.markers["binary"]; ok {
+		bv, err := newBinaryValue(typ, enc)
+		if err != nil {
+			return fieldError(UnsupportedType, path, name, "invalid type %q for flag %q: %w", typ, name, err)
+		}
+		fs.Var(bv, name, help)
+	} else if reflect.PointerTo(typ).Implements(i) {
+		pv := reflect.New(typ)
+		if len(deflts) == 0 && fv.IsValid() && fv.Kind() != reflect.Pointer && !fv.IsZero() {
+			// The current value of the field is the default
+			// value of the flag
+			pv.Elem().Set(fv)
+		}
+		fs.Var(pv.Interface().(flag.Value), name, help)
+	} else if tv, err := newTypeValue(typ, ft); err != nil {
+		return fieldError(InvalidTag, path, name, "invalid flag %q: %w", name, err)
+	} else if tv != nil {
+		fs.Var(tv, name, help)
+	} else if isTextType(typ) {
+		fs.Var(newTextValue(typ), name, help)
+	} else if _, ok := ft.markers["count"]; ok {
+		cv, err := newCountValue(typ)
+		if err != nil {
+			return fieldError(UnsupportedType, path, name, "invalid type %q for flag %q: %w", typ, name, err)
+		}
+		fs.Var(cv, name, help)
+	} else if _, ok := ft.markers["bytesize"]; ok {
+		bv, err := newByteSizeValue(typ)
+		if err != nil {
+			return fieldError(UnsupportedType, path, name, "invalid type %q for flag %q: %w", typ, name, err)
+		}
+		fs.Var(bv, name, help)
+	} else if _, ok := ft.markers["rune"]; ok {
+		rv, err := newRuneValue(typ)
+		if err != nil {
+			return fieldError(UnsupportedType, path, name, "invalid type %q for flag %q: %w", typ, name, err)
+		}
+		fs.Var(rv, name, help)
+	} else {
+		switch kind {
+		case reflect.Bool:
+			fs.Bool(name, false, help)
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			var d time.Duration
+			if _, ok := ft.markers["duration"]; ok && kind != reflect.Int64 {
+				return fieldError(UnsupportedType, path, name, "invalid type %q for duration flag %q", typ, name)
+			}
+			unit, err := durationUnit(ft)
+			if err != nil {
+				return fieldError(InvalidTag, path, name, "invalid flag %q: %w", name, err)
+			}
+			switch {
+			case !isDuration(typ, ft):
+				if unit != 0 {
+					return fieldError(UnsupportedType, path, name, "invalid type %q for flag %q with unit", typ, name)
+				}
+				fs.Int64(name, 0, help)
+			case unit != 0:
+				fs.Var(&durationValue{typ: typ, unit: unit}, name, help)
 			default:
-				panic(fmt.Sprintf("invalid type %q for flag %q. It doesn't implements %q or it's not a type recognized by the flag package", typ, name, i))
+				fs.Duration(name, d, help)
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			fs.Uint64(name, 0, help)
+		case reflect.Float32, reflect.Float64:
+			fs.Float64(name, 0.0, help)
+		case reflect.Complex64, reflect.Complex128:
+			fs.Var(&complexValue{typ: typ}, name, help)
+		case reflect.String:
+			fs.String(name, "", help)
+		case reflect.Slice:
+			if typ.Elem().Kind() == reflect.Uint8 {
+				bv, err := newBytesValue(typ, ft.markers["encoding"])
+				if err != nil {
+					return fieldError(InvalidTag, path, name, "invalid flag %q: %w", name, err)
+				}
+				fs.Var(bv, name, help)
+				break
+			}
+			_, shell := ft.markers["shellwords"]
+			if shell && ft.markers["sep"] != "" {
+				return fieldError(InvalidTag, path, name, "invalid flag %q: sep and shellwords markers are mutually exclusive", name)
+			}
+			sv, err := newSliceValue(typ, ft.markers["sep"], shell)
+			if err != nil {
+				return fieldError(UnsupportedType, path, name, "invalid type %q for flag %q: %w", typ, name, err)
+			}
+			fs.Var(sv, name, help)
+		case reflect.Array:
+			av, err := newArrayValue(typ, ft.markers["sep"])
+			if err != nil {
+				return fieldError(UnsupportedType, path, name, "invalid type %q for flag %q: %w", typ, name, err)
+			}
+			fs.Var(av, name, help)
+		case reflect.Map:
+			mv, err := newMapValue(typ, ft.markers["sep"], o.mapParsers[name])
+			if err != nil {
+				return fieldError(UnsupportedType, path, name, "invalid type %q for flag %q: %w", typ, name, err)
+			}
+			fs.Var(mv, name, help)
+		case reflect.Interface:
+			iv, err := newImplValue(typ)
+			if err != nil {
+				return fieldError(UnsupportedType, path, name, "invalid type %q for flag %q: %w", typ, name, err)
 			}
+			fs.Var(iv, name, help)
+		default:
+			return fieldError(UnsupportedType, path, name, "invalid type %q for flag %q. It doesn't implement %q or it's not a type recognized by the flag package", typ, name, i)
+		}
+	}
+	fl := fs.Lookup(name)
+	if _, ok := fl.Value.(ContextSetter); ok {
+		fl.Value = &contextValue{Value: fl.Value}
+	}
+	if err := addChecks(fl, typ, ft); err != nil {
+		return fieldError(InvalidTag, path, name, "invalid flag %q: %w", name, err)
+	}
+	if _, ok := ft.markers["fromfile"]; ok {
+		fl.Value = &fileValue{Value: fl.Value}
+	}
+	if err := applyDefault(fl, f, deflts); err != nil {
+		return err
+	}
+	for _, alias := range f.aliases {
+		if fs.Lookup(alias) != nil {
+			return fieldError(DuplicateFlag, path, alias, "flag %q already defined", alias)
+		}
+		fs.Var(fl.Value, alias, fmt.Sprintf("alias for -%s", name))
+	}
+	if f.negated != "" {
+		if kind != reflect.Bool {
+			return fieldError(UnsupportedType, path, name, "invalid type %q for negatable flag %q", typ, name)
 		}
-		if deflt != "" {
-			fl := fs.Lookup(name)
-			if err := fl.Value.Set(deflt); err != nil {
-				panic(fmt.Sprintf("invalid default value %q for flag %q: %v", deflt, name, err))
+		if fs.Lookup(f.negated) != nil {
+			return fieldError(DuplicateFlag, path, f.negated, "flag %q already defined", f.negated)
+		}
+		fs.Var(&negatedValue{fl.Value}, f.negated, fmt.Sprintf("negate -%s", name))
+	}
+	_, hidden := ft.markers["hidden"]
+	_, sensitive := ft.markers["sensitive"]
+	group := ft.markers["group"]
+	updateFlagSetInfo(fs, func(info *flagSetInfo) {
+		for _, name := range f.names() {
+			info.added[name] = true
+			info.order = append(info.order, name)
+			if hidden {
+				info.hidden[name] = true
+			}
+			if sensitive {
+				info.sensitive[name] = true
+			}
+			if group != "" {
+				info.groups[name] = group
 			}
-			fl.DefValue = fl.Value.String()
+		}
+		if group == "" {
+			return
+		}
+		for _, g := range info.groupOrder {
+			if g == group {
+				return
+			}
+		}
+		info.groupOrder = append(info.groupOrder, group)
+	})
+	if o.caseInsensitive {
+		var err error
+		updateFlagSetInfo(fs, func(info *flagSetInfo) {
+			for _, name := range f.names() {
+				lower := strings.ToLower(name)
+				if other, ok := info.lowerNames[lower]; ok && other != name {
+					err = fieldError(DuplicateFlag, path, name, "flag %q conflicts with flag %q when ignoring case", name, other)
+					return
+				}
+				info.lowerNames[lower] = name
+			}
+		})
+		if err != nil {
+			return err
 		}
 	}
+	return nil
+}
+
+// applyDefault sets the default value of fl to the values of deflts,
+// unless the environment variable given by the tag of f is set.
+func applyDefault(fl *flag.Flag, f *taggedField, deflts []string) error {
+	if env := f.tag.markers["env"]; env != "" {
+		if ev := os.Getenv(env); ev != "" {
+			if err := setDefault(fl, f, ev); err != nil {
+				return fieldError(InvalidDefault, f.path, fl.Name, "invalid value %q of environment variable %q for flag %q: %w", ev, env, fl.Name, err)
+			}
+			return nil
+		}
+	}
+	for _, deflt := range deflts {
+		if err := setDefault(fl, f, deflt); err != nil {
+			return fieldError(InvalidDefault, f.path, fl.Name, "invalid default value %q for flag %q: %w", deflt, fl.Name, err)
+		}
+	}
+	return nil
+}
+
+// setDefault sets the default value of fl to deflt, which must be in
+// the range of the field f as the integer flags hold 64-bit values.
+func setDefault(fl *flag.Flag, f *taggedField, deflt string) error {
+	set := fl.Value.Set
+	if ds, ok := fl.Value.(defaultSetter); ok {
+		set = ds.setDefault
+	}
+	if err := set(deflt); err != nil {
+		return err
+	}
+	if g, ok := fl.Value.(flag.Getter); ok {
+		typ := f.Type
+		if typ.Kind() == reflect.Pointer {
+			typ = typ.Elem()
+		}
+		if v := reflect.ValueOf(g.Get()); v.IsValid() && overflows(v, typ) {
+			return fmt.Errorf("value %v out of range of type %q", v, typ)
+		}
+	}
+	fl.DefValue = fl.Value.String()
+	return nil
 }
 
 // SetFromFlags sets the value of the fields in the struct contained
 // in s with the value of the flags defined in fs. It uses the tag of
 // the struct fields to determine the fields whose value should be set
-// and to determine the corresponding flag to use.
+// and to determine the corresponding flag to use. It panics if an
+// error occurs, see SetFromFlagsErr.
 func SetFromFlags(s any, fs *flag.FlagSet) {
+	if err := SetFromFlagsErr(s, fs); err != nil {
+		panic(err)
+	}
+}
+
+// SetFromFlagsErr is like SetFromFlags but it returns an error instead
+// of panicking if fs has not been parsed, if s is not a struct or if
+// the value of a flag can't be assigned to its corresponding field.
+func SetFromFlagsErr(s any, fs *flag.FlagSet) error {
+	return SetFromFlagsWith(s, fs)
+}
+
+// SetFromFlagsWith is like SetFromFlagsErr but the way the flags are
+// derived from the struct fields can be customized using opts. opts
+// must be the options used to add the flags to fs.
+func SetFromFlagsWith(s any, fs *flag.FlagSet, opts ...Option) (err error) {
+	o := newFlagSetOptions(fs, opts)
+	defer o.handleError(fs, &err)
+	if !fs.Parsed() {
+		return errors.New("flag not parsed")
+	}
+	v := reflect.Indirect(reflect.ValueOf(s))
+	if v.Kind() != reflect.Struct {
+		return errors.New("not a struct")
+	}
+	fields, err := getFlagFields(v.Type(), o)
+	if err != nil {
+		return err
+	}
+	if err := setFields(context.Background(), v, fs, fields, o); err != nil {
+		return err
+	}
+	return setArgs(v, fs, o)
+}
+
+// SetFromFlagsContext is like SetFromFlagsWith but ctx is given to
+// the SetContext method of the values of the flags implementing
+// ContextSetter, so that their parsing can be cancelled. The other
+// functions setting the struct fields use context.Background().
+func SetFromFlagsContext(ctx context.Context, s any, fs *flag.FlagSet, opts ...Option) (err error) {
+	o := newFlagSetOptions(fs, opts)
+	defer o.handleError(fs, &err)
 	if !fs.Parsed() {
-		panic("flag not parsed")
+		return errors.New("flag not parsed")
 	}
 	v := reflect.Indirect(reflect.ValueOf(s))
 	if v.Kind() != reflect.Struct {
-		panic("not a struct")
+		return errors.New("not a struct")
+	}
+	fields, err := getFlagFields(v.Type(), o)
+	if err != nil {
+		return err
+	}
+	if err := setFields(ctx, v, fs, fields, o); err != nil {
+		return err
+	}
+	return setArgs(v, fs, o)
+}
+
+// SetFromFlagsReport is like SetFromFlagsWith but it also returns the
+// set of the flags explicitly given on the command line, keyed by the
+// name of the flag. A flag given through one of its aliases or its
+// negated flag is reported under its name. It lets a layered
+// configuration override the values read from a file or from the
+// environment only with the flags actually given.
+func SetFromFlagsReport(s any, fs *flag.FlagSet, opts ...Option) (_ map[string]bool, err error) {
+	o := newFlagSetOptions(fs, opts)
+	defer o.handleError(fs, &err)
+	if !fs.Parsed() {
+		return nil, errors.New("flag not parsed")
+	}
+	v := reflect.Indirect(reflect.ValueOf(s))
+	if v.Kind() != reflect.Struct {
+		return nil, errors.New("not a struct")
+	}
+	fields, err := getFlagFields(v.Type(), o)
+	if err != nil {
+		return nil, err
 	}
-	indexes := make(map[string][]int)
-	getFlagIndexes(indexes, &v, nil)
+	if err := setFields(context.Background(), v, fs, fields, o); err != nil {
+		return nil, err
+	}
+	if err := setArgs(v, fs, o); err != nil {
+		return nil, err
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(fl *flag.Flag) {
+		if f := fields[fl.Name]; f != nil {
+			set[f.name] = true
+		}
+	})
+	return set, nil
+}
+
+// SetFromFlagsSubset is like SetFromFlagsWith but it only sets the
+// fields corresponding to the flags names, which can also be aliases
+// or negated flags. The other fields, e.g. changed by the program since
+// the flags were parsed, are left untouched, as are the positional
+// arguments. An unknown name is an error.
+func SetFromFlagsSubset(s any, fs *flag.FlagSet, names []string, opts ...Option) (err error) {
+	o := newFlagSetOptions(fs, opts)
+	defer o.handleError(fs, &err)
+	if !fs.Parsed() {
+		return errors.New("flag not parsed")
+	}
+	v := reflect.Indirect(reflect.ValueOf(s))
+	if v.Kind() != reflect.Struct {
+		return errors.New("not a struct")
+	}
+	fields, err := getFlagFields(v.Type(), o)
+	if err != nil {
+		return err
+	}
+	selected := make(map[string]bool, len(names))
+	for _, name := range names {
+		f := fields[name]
+		if f == nil {
+			return fmt.Errorf("unknown flag %q", name)
+		}
+		selected[f.path] = true
+	}
+	subset := make(map[string]*taggedField)
+	for name, f := range fields {
+		if selected[f.path] {
+			subset[name] = f
+		}
+	}
+	return setFields(context.Background(), v, fs, subset, o)
+}
+
+// setFields sets the fields of the struct v with the value of the
+// flags of fs, fields giving the field corresponding to each flag.
+func setFields(ctx context.Context, v reflect.Value, fs *flag.FlagSet, fields map[string]*taggedField, o *options) error {
+	if err := applyContextValues(ctx, fs, fields); err != nil {
+		return err
+	}
+	var err error
 	explicit := make(map[string]bool)
+	negated := make(map[string]bool)
 	fs.Visit(func(fl *flag.Flag) {
-		explicit[fl.Name] = true
+		f := fields[fl.Name]
+		if f == nil {
+			return
+		}
+		if f.negated != "" && explicit[f.path] && negated[f.path] != (fl.Name == f.negated) {
+			err = fmt.Errorf("flags -%s and -%s can't be used together", f.name, f.negated)
+		}
+		explicit[f.path] = true
+		negated[f.path] = fl.Name == f.negated
 	})
+	if err != nil {
+		return err
+	}
 	fs.VisitAll(func(fl *flag.Flag) {
-		index := indexes[fl.Name]
-		if index == nil {
+		if err != nil {
+			return
+		}
+		f := fields[fl.Name]
+		if f == nil {
 			return
 		}
 		var flv reflect.Value
-		if getter, ok := fl.Value.(flag.Getter); ok {
+		val := unwrapValue(fl.Value)
+		if iv, ok := val.(*implValue); ok {
+			if iv.name == "" {
+				return
+			}
+			fiv := fieldByIndex(v, f.index)
+			if !o.alwaysApplyDefaults && !fiv.IsZero() && fl.Value.String() == fl.DefValue && !explicit[f.path] {
+				return
+			}
+			if flv, err = iv.construct(); err != nil {
+				err = fieldError(ConversionFailed, f.path, fl.Name, "flag %q: %w", fl.Name, err)
+				return
+			}
+			target := setterTarget(f, fiv)
+			fiv = target
+			if fiv.Kind() == reflect.Pointer {
+				if fiv.IsNil() {
+					fiv.Set(reflect.New(fiv.Type().Elem()))
+				}
+				fiv = fiv.Elem()
+			}
+			fiv.Set(flv)
+			err = callSetter(v, f, fl.Name, target)
+			return
+		}
+		if getter, ok := val.(flag.Getter); ok {
 			flv = reflect.ValueOf(getter.Get())
 		} else {
-			flv = reflect.ValueOf(fl.Value)
+			flv = reflect.ValueOf(val)
+		}
+		fiv := fieldByIndex(v, f.index)
+		if f.IsExported() && fiv.Kind() == reflect.Pointer && !fiv.IsNil() && fiv.Interface() == val {
+			// The flag shares the value of the field
+			return
+		}
+		if fiv.Kind() == reflect.Pointer && fiv.IsNil() && !explicit[f.path] && !hasDefault(f) {
+			// A nil pointer tells that the flag isn't set
+			return
+		}
+		if !o.alwaysApplyDefaults && !fiv.IsZero() && fl.Value.String() == fl.DefValue && !explicit[f.path] {
+			return
 		}
-		fiv := v.FieldByIndex(index)
-		if !fiv.IsZero() && fl.Value.String() == fl.DefValue && !explicit[fl.Name] {
+		target := setterTarget(f, fiv)
+		fiv = target
+		if !flv.IsValid() {
+			err = fieldError(ConversionFailed, f.path, fl.Name, "flag %q: no value to assign to field of type %q", fl.Name, fiv.Type())
 			return
 		}
+		if flv.Kind() == reflect.Func {
+			// The flag was defined with fs.Func or fs.BoolFunc,
+			// only its string representation is available
+			ftyp := fiv.Type()
+			if ftyp.Kind() == reflect.Pointer {
+				ftyp = ftyp.Elem()
+			}
+			if ftyp.Kind() != reflect.String {
+				err = fieldError(ConversionFailed, f.path, fl.Name, "flag %q: cannot assign the value of a function flag to field of type %q", fl.Name, fiv.Type())
+				return
+			}
+			str := val.String()
+			if str == "" {
+				// The function flags of the flag package don't
+				// keep their value, the field is left untouched
+				return
+			}
+			flv = reflect.ValueOf(str)
+		}
 		if fiv.Type() != flv.Type() {
 			if fiv.Kind() == reflect.Pointer {
 				if fiv.IsNil() {
@@ -140,36 +1158,237 @@ func SetFromFlags(s any, fs *flag.FlagSet) {
 			if flv.Kind() == reflect.Pointer {
 				flv = flv.Elem()
 			}
-			if !flv.Type().AssignableTo(fiv.Type()) {
-				// Will panic if flag value is not convertible to field type
+			if !flv.Type().AssignableTo(fiv.Type()) && reflect.PointerTo(fiv.Type()).Implements(flagValueType) {
+				// The field parses the string representation of
+				// the flag itself
+				pv := reflect.New(fiv.Type())
+				if err = pv.Interface().(flag.Value).Set(fl.Value.String()); err != nil {
+					err = fieldError(ConversionFailed, f.path, fl.Name, "flag %q: %w", fl.Name, err)
+					return
+				}
+				flv = pv.Elem()
+			} else if !flv.Type().AssignableTo(fiv.Type()) {
+				if !flv.Type().ConvertibleTo(fiv.Type()) {
+					err = fieldError(ConversionFailed, f.path, fl.Name, "flag %q: cannot convert value of type %q to field of type %q", fl.Name, flv.Type(), fiv.Type())
+					return
+				}
+				if overflows(flv, fiv.Type()) {
+					err = fieldError(ConversionFailed, f.path, fl.Name, "flag %q: value %v overflows field of type %q", fl.Name, flv, fiv.Type())
+					return
+				}
 				flv = flv.Convert(fiv.Type())
 			}
 		}
+		if o.mergeSlices && explicit[f.path] {
+			flv = mergeValues(fiv, flv)
+		}
 		fiv.Set(flv)
+		err = callSetter(v, f, fl.Name, target)
 	})
+	if err != nil {
+		return err
+	}
+	return applyDefaultFrom(v, fs, fields, explicit, o)
 }
 
-func getFlagIndexes(indexes map[string][]int, v *reflect.Value, pindex []int) {
-	fields := reflect.VisibleFields(v.Type())
-	for _, fi := range fields {
-		if fi.Anonymous || !fi.IsExported() {
-			continue
+// mergeValues returns the elements of the slice or map v followed by
+// the elements of the slice or map w, of the same type. It returns w
+// if v isn't a slice or a map, or is a byte slice.
+func mergeValues(v reflect.Value, w reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 || v.Len() == 0 {
+			return w
 		}
-		index := make([]int, len(pindex)+len(fi.Index))
-		copy(index, pindex)
-		copy(index[len(pindex):], fi.Index)
-		tag := fi.Tag.Get(TagKey)
-		if tag == "" {
-			if fi.Type.Kind() == reflect.Struct {
-				fiv := v.FieldByIndex(fi.Index)
-				getFlagIndexes(indexes, &fiv, index)
+		merged := reflect.MakeSlice(v.Type(), 0, v.Len()+w.Len())
+		return reflect.AppendSlice(reflect.AppendSlice(merged, v), w)
+	case reflect.Map:
+		if v.Len() == 0 {
+			return w
+		}
+		merged := reflect.MakeMapWithSize(v.Type(), v.Len()+w.Len())
+		for _, m := range []reflect.Value{v, w} {
+			iter := m.MapRange()
+			for iter.Next() {
+				merged.SetMapIndex(iter.Key(), iter.Value())
 			}
-			continue
 		}
-		name, _, _ := parseTag(tag)
-		if _, ok := indexes[name]; ok {
-			panic(fmt.Sprintf("duplicate flag %q", name))
+		return merged
+	}
+	return w
+}
+
+// hasDefault reports whether the flag derived from f has a default
+// value, given either by its tag or by the environment variable of
+// its env marker.
+func hasDefault(f *taggedField) bool {
+	if f.tag.deflt != "" || emptyDefault(f.Type, f.tag) {
+		return true
+	}
+	env := f.tag.markers["env"]
+	return env != "" && os.Getenv(env) != ""
+}
+
+// ResetToDefaults sets the fields of the struct contained in s
+// carrying a flag tag to the default value of their flag, as given by
+// the tag or by the environment variable of the env marker. The fields
+// without default value are set to their zero value.
+func ResetToDefaults(s any, opts ...Option) error {
+	v := reflect.Indirect(reflect.ValueOf(s))
+	if v.Kind() != reflect.Struct {
+		return errors.New("not a struct")
+	}
+	o := newOptions(opts)
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	defer forgetFlagSet(fs)
+	if err := addFlags(fs, v.Type(), o); err != nil {
+		return err
+	}
+	if err := fs.Parse(nil); err != nil {
+		return err
+	}
+	fields, err := getFlagFields(v.Type(), o)
+	if err != nil {
+		return err
+	}
+	for name, f := range fields {
+		if fv, err := v.FieldByIndexErr(f.index); err == nil && f.IsExported() {
+			fv.Set(reflect.Zero(fv.Type()))
+		}
+		if !hasDefault(f) {
+			delete(fields, name)
+		}
+	}
+	return setFields(context.Background(), v, fs, fields, o)
+}
+
+// Parse creates a new T, adds the flags derived from its fields to a
+// new flag.FlagSet, parses args and sets the fields of the new T from
+// the flags. The flag.FlagSet is returned to give access to the
+// remaining arguments. The flag.FlagSet uses the ContinueOnError
+// error handling, so parsing errors, including flag.ErrHelp, are
+// returned.
+func Parse[T any](args []string, opts ...Option) (*T, *flag.FlagSet, error) {
+	s := new(T)
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	if err := AddFlagsWith(fs, s, opts...); err != nil {
+		return nil, nil, err
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, fs, err
+	}
+	if err := SetFromFlagsWith(s, fs, opts...); err != nil {
+		return nil, fs, err
+	}
+	return s, fs, nil
+}
+
+// Validate checks, without a FlagSet, that the flags can be derived
+// from the fields of the struct contained in s: the tags must be well
+// formed, the types of the fields supported, the default values valid
+// and the names of the flags unique. Unlike AddFlagsWith, it doesn't
+// stop at the first problem, the returned error joining the errors of
+// all the offending fields.
+func Validate(s any, opts ...Option) error {
+	v := reflect.Indirect(reflect.ValueOf(s))
+	if v.Kind() != reflect.Struct {
+		return errors.New("not a struct")
+	}
+	o := newOptions(opts)
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	defer forgetFlagSet(fs)
+	names := make(map[string]bool)
+	err := visitAllFields(v.Type(), o, func(f *taggedField) error {
+		for _, name := range f.names() {
+			if names[name] {
+				return fieldError(DuplicateFlag, f.path, name, "duplicate flag %q", name)
+			}
+		}
+		for _, name := range f.names() {
+			names[name] = true
+		}
+		return addFlag(fs, f, reflect.Value{}, o)
+	})
+	if _, aerr := argsField(v.Type(), o); aerr != nil {
+		err = errors.Join(err, aerr)
+	}
+	return err
+}
+
+// FlagIndexes returns the index sequences, as used by
+// reflect.Value.FieldByIndex, of the fields of the struct contained in
+// s, keyed by the name and the aliases of their flag. The sequences
+// of the nested fields may traverse pointers to structs. It lets
+// external tools implement their own binding logic.
+func FlagIndexes(s any, opts ...Option) (map[string][]int, error) {
+	v := reflect.Indirect(reflect.ValueOf(s))
+	if v.Kind() != reflect.Struct {
+		return nil, errors.New("not a struct")
+	}
+	fields, err := getFlagFields(v.Type(), newOptions(opts))
+	if err != nil {
+		return nil, err
+	}
+	indexes := make(map[string][]int, len(fields))
+	for name, f := range fields {
+		indexes[name] = append([]int(nil), f.index...)
+	}
+	return indexes, nil
+}
+
+// MustParse is like Parse but it parses os.Args[1:], using os.Args[0]
+// as the name of the flag.FlagSet, and checks the required flags. On
+// error, it prints the error and the usage message to the output of
+// the flag.FlagSet and exits with status 2, like flag.CommandLine
+// does. If -h or -help is given and not defined, the usage message is
+// printed and it exits with status 0. The hidden flags are omitted
+// from the usage message. It is meant to be the entry point of simple
+// commands.
+func MustParse[T any](opts ...Option) *T {
+	s := new(T)
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage of %s:\n", fs.Name())
+		PrintVisibleDefaults(fs)
+	}
+	if err := AddFlagsWith(fs, s, opts...); err != nil {
+		fmt.Fprintln(fs.Output(), err)
+		os.Exit(2)
+	}
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		// The error and the usage message are already printed
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		os.Exit(2)
+	}
+	err := SetFromFlagsWith(s, fs, opts...)
+	if err == nil {
+		err = CheckRequired(s, fs, opts...)
+	}
+	if err != nil {
+		fmt.Fprintln(fs.Output(), err)
+		fs.Usage()
+		os.Exit(2)
+	}
+	return s
+}
+
+// getFlagFields returns the tagged fields of the struct type typ
+// keyed by the name and the aliases of their flag.
+func getFlagFields(typ reflect.Type, o *options) (map[string]*taggedField, error) {
+	fields := make(map[string]*taggedField)
+	err := visitFields(typ, o, func(f *taggedField) error {
+		for _, name := range f.names() {
+			if _, ok := fields[name]; ok {
+				return fieldError(DuplicateFlag, f.path, name, "duplicate flag %q", name)
+			}
+			fields[name] = f
 		}
-		indexes[name] = index
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
+	return fields, nil
 }
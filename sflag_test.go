@@ -0,0 +1,349 @@
+package sflag
+
+import (
+	"flag"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// setFromArgs adds the flags derived from s to a new FlagSet, parses
+// args and sets the fields of s from the flags, with opts.
+func setFromArgs(t *testing.T, s any, args []string, opts ...Option) error {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	if err := AddFlagsWith(fs, s, opts...); err != nil {
+		t.Fatalf("AddFlagsWith: %v", err)
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return SetFromFlagsWith(s, fs, opts...)
+}
+
+func TestParseTag(t *testing.T) {
+	tests := []struct {
+		tag     string
+		want    flagTag
+		wantErr bool
+	}{
+		{
+			tag:  `port,80,port to listen on`,
+			want: flagTag{name: "port", deflt: "80", help: "port to listen on"},
+		},
+		{
+			tag:  `a\,b,x\,y,help`,
+			want: flagTag{name: "a,b", deflt: "x,y", help: "help"},
+		},
+		{
+			tag:  `name,1\,2\,3,list|sep=\,`,
+			want: flagTag{name: "name", deflt: "1,2,3", help: "list", markers: map[string]string{"sep": ","}},
+		},
+		{
+			tag:  `n,d,a\|b|oneof=x,y|hidden`,
+			want: flagTag{name: "n", deflt: "d", help: "a|b", markers: map[string]string{"oneof": "x,y", "hidden": ""}},
+		},
+		{
+			tag:  `n,\\,h`,
+			want: flagTag{name: "n", deflt: `\`, help: "h"},
+		},
+		{
+			tag:  `,,`,
+			want: flagTag{},
+		},
+		{
+			tag:  `name=a\;b;default=;usage=u|v;min=1`,
+			want: flagTag{name: "a;b", emptyDeflt: true, help: "u|v", markers: map[string]string{"min": "1"}},
+		},
+		{
+			tag:     `a,b`,
+			wantErr: true,
+		},
+		{
+			tag:     `name=a;;usage=u`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		ft, err := parseTag(tt.tag)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: got %+v, want an error", tt.tag, ft)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.tag, err)
+			continue
+		}
+		if tt.want.markers == nil {
+			tt.want.markers = map[string]string{}
+		}
+		if !reflect.DeepEqual(*ft, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.tag, *ft, tt.want)
+		}
+	}
+}
+
+func TestEmptyKeyedDefault(t *testing.T) {
+	var s struct {
+		S string   `flag:"name=s;default=;usage=s"`
+		P *string  `flag:"name=p;default=;usage=p"`
+		L []string `flag:"name=l;default=;usage=l"`
+		N *int     `flag:"name=n;default=;usage=n"`
+	}
+	s.S = "x"
+	if err := setFromArgs(t, &s, nil, WithAlwaysApplyDefaults()); err != nil {
+		t.Fatal(err)
+	}
+	if s.S != "" {
+		t.Errorf("got S=%q, want the empty string", s.S)
+	}
+	if s.P == nil || *s.P != "" {
+		t.Errorf("got P=%v, want a pointer to the empty string", s.P)
+	}
+	if want := []string{""}; !reflect.DeepEqual(s.L, want) {
+		t.Errorf("got L=%q, want %q", s.L, want)
+	}
+	// The empty default of a non-string field means no default value
+	if s.N != nil {
+		t.Errorf("got N=%d, want nil", *s.N)
+	}
+}
+
+func TestNilPointerToStruct(t *testing.T) {
+	type sub struct {
+		Name string `flag:"name,,name"`
+		Port int    `flag:"port,80,port"`
+	}
+	var s struct {
+		Sub   *sub
+		Inner struct {
+			Deep *sub
+		}
+	}
+	args := []string{"-sub.name", "x", "-inner.deep.port", "8080"}
+	if err := setFromArgs(t, &s, args, WithNestedPrefix()); err != nil {
+		t.Fatal(err)
+	}
+	if s.Sub == nil || s.Sub.Name != "x" || s.Sub.Port != 80 {
+		t.Errorf("got Sub=%+v, want {x 80}", s.Sub)
+	}
+	if s.Inner.Deep == nil || s.Inner.Deep.Port != 8080 {
+		t.Errorf("got Inner.Deep=%+v, want {\"\" 8080}", s.Inner.Deep)
+	}
+}
+
+type Common struct {
+	Verbose bool `flag:"verbose,,verbose"`
+}
+
+type DB struct {
+	Host string `flag:"host,localhost,host"`
+}
+
+type Level int
+
+func TestEmbeddedStructs(t *testing.T) {
+	var s struct {
+		Common
+		*DB `flag:"db,,"`
+		Level
+		Name string `flag:"name,,name"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := AddFlagsWith(fs, &s, WithNestedPrefix()); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	fs.VisitAll(func(fl *flag.Flag) {
+		names = append(names, fl.Name)
+	})
+	if want := []string{"db.host", "name", "verbose"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got flags %q, want %q", names, want)
+	}
+	if err := fs.Parse([]string{"-verbose", "-db.host", "example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetFromFlagsWith(&s, fs, WithNestedPrefix()); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Verbose || s.DB == nil || s.Host != "example.com" {
+		t.Errorf("got Verbose=%v DB=%+v", s.Verbose, s.DB)
+	}
+	var p struct {
+		*Common
+	}
+	if err := setFromArgs(t, &p, []string{"-verbose"}); err != nil {
+		t.Fatal(err)
+	}
+	if p.Common == nil || !p.Verbose {
+		t.Errorf("got Common=%+v, want {true}", p.Common)
+	}
+}
+
+func TestAddFlagsAuto(t *testing.T) {
+	s := struct {
+		Host string
+		Port int
+	}{Host: "localhost", Port: 80}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := AddFlagsAuto(fs, &s); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse([]string{"-port", "9090"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetFromFlagsErr(&s, fs); err != nil {
+		t.Fatal(err)
+	}
+	if s.Host != "localhost" || s.Port != 9090 {
+		t.Errorf("got %+v, want Host=localhost Port=9090", s)
+	}
+}
+
+// listValue is a flag.Value accumulating its values.
+type listValue struct {
+	items []string
+}
+
+func (l *listValue) Set(s string) error {
+	l.items = append(l.items, s)
+	return nil
+}
+
+func (l *listValue) String() string {
+	if l == nil {
+		return ""
+	}
+	return strings.Join(l.items, ",")
+}
+
+func TestSharedValue(t *testing.T) {
+	shared := &listValue{items: []string{"seed"}}
+	s := struct {
+		List *listValue `flag:"item,,items"`
+	}{List: shared}
+	if err := setFromArgs(t, &s, []string{"-item", "a", "-item", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if s.List != shared {
+		t.Error("the field doesn't hold the pre-existing pointer anymore")
+	}
+	if want := []string{"seed", "a", "b"}; !reflect.DeepEqual(shared.items, want) {
+		t.Errorf("got items %q, want %q", shared.items, want)
+	}
+}
+
+// boolFunc is a boolean flag.Value calling a function, like the
+// flags defined by fs.BoolFunc.
+type boolFunc func(string) error
+
+func (f boolFunc) Set(s string) error {
+	return f(s)
+}
+
+func (f boolFunc) String() string {
+	return ""
+}
+
+func (f boolFunc) IsBoolFlag() bool {
+	return true
+}
+
+func TestFuncFlags(t *testing.T) {
+	var added struct {
+		Port int `flag:"port,80,port"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := AddFlagsWith(fs, &added); err != nil {
+		t.Fatal(err)
+	}
+	var mode string
+	fs.Func("mode", "mode", func(s string) error {
+		mode = s
+		return nil
+	})
+	fs.Var(boolFunc(func(string) error {
+		return nil
+	}), "trace", "trace")
+	if err := fs.Parse([]string{"-port", "8080", "-mode", "fast", "-trace"}); err != nil {
+		t.Fatal(err)
+	}
+	if mode != "fast" {
+		t.Errorf("got mode %q, want fast", mode)
+	}
+	s := struct {
+		Port int    `flag:"port,80,port"`
+		Mode string `flag:"mode,,mode"`
+	}{Mode: "slow"}
+	if err := SetFromFlagsErr(&s, fs); err != nil {
+		t.Fatal(err)
+	}
+	// The value of the flag defined by fs.Func can't be retrieved
+	if s.Port != 8080 || s.Mode != "slow" {
+		t.Errorf("got %+v, want Port=8080 Mode=slow", s)
+	}
+	var bad struct {
+		Trace bool `flag:"trace,,trace"`
+	}
+	if err := SetFromFlagsErr(&bad, fs); err == nil {
+		t.Error("got no error for a function flag assigned to a bool field")
+	}
+}
+
+func TestPointerFields(t *testing.T) {
+	type config struct {
+		B *bool   `flag:"b,,b"`
+		I *int    `flag:"i,,i"`
+		S *string `flag:"s,,s"`
+		D *int    `flag:"d,3,with default"`
+	}
+	var c config
+	if err := setFromArgs(t, &c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.B != nil || c.I != nil || c.S != nil {
+		t.Errorf("got B=%v I=%v S=%v, want nil", c.B, c.I, c.S)
+	}
+	if c.D == nil || *c.D != 3 {
+		t.Errorf("got D=%v, want 3", c.D)
+	}
+	c = config{}
+	if err := setFromArgs(t, &c, []string{"-b=false", "-i", "0", "-s", ""}); err != nil {
+		t.Fatal(err)
+	}
+	if c.B == nil || *c.B || c.I == nil || *c.I != 0 || c.S == nil || *c.S != "" {
+		t.Errorf("got B=%v I=%v S=%v, want pointers to zero values", c.B, c.I, c.S)
+	}
+}
+
+func TestPointerToSlice(t *testing.T) {
+	type config struct {
+		Ints    *[]int    `flag:"int,,ints"`
+		Strings *[]string `flag:"str,,strings"`
+		Deflt   *[]string `flag:"deflt,a,default"`
+	}
+	var c config
+	if err := setFromArgs(t, &c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.Ints != nil || c.Strings != nil {
+		t.Errorf("got Ints=%v Strings=%v, want nil", c.Ints, c.Strings)
+	}
+	if c.Deflt == nil || !reflect.DeepEqual(*c.Deflt, []string{"a"}) {
+		t.Errorf("got Deflt=%v, want [a]", c.Deflt)
+	}
+	c = config{}
+	if err := setFromArgs(t, &c, []string{"-int", "1", "-int", "0x2", "-str", "x"}); err != nil {
+		t.Fatal(err)
+	}
+	if c.Ints == nil || !reflect.DeepEqual(*c.Ints, []int{1, 2}) {
+		t.Errorf("got Ints=%v, want [1 2]", c.Ints)
+	}
+	if c.Strings == nil || !reflect.DeepEqual(*c.Strings, []string{"x"}) {
+		t.Errorf("got Strings=%v, want [x]", c.Strings)
+	}
+}
@@ -0,0 +1,83 @@
+package sflag
+
+import (
+	"errors"
+	"strings"
+)
+
+// splitShellWords splits s into words like a minimal shell lexer: the
+// words are separated by white space, the characters enclosed in
+// single quotes are taken literally, the characters enclosed in
+// double quotes are taken literally except for \" and \\, and a
+// backslash outside of quotes escapes the next character. The quotes
+// and the escaping backslashes are removed, e.g. `--foo bar "baz qux"`
+// is split into "--foo", "bar" and "baz qux".
+func splitShellWords(s string) ([]string, error) {
+	var (
+		words  []string
+		word   strings.Builder
+		inWord bool
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+			continue
+		case c == '\\':
+			i++
+			if i == len(s) {
+				return nil, errors.New("trailing backslash")
+			}
+			word.WriteByte(s[i])
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			word.WriteString(s[i+1 : i+1+end])
+			i += end + 1
+		case c == '"':
+			i++
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\') {
+					i++
+				}
+				word.WriteByte(s[i])
+			}
+			if i == len(s) {
+				return nil, errors.New("unterminated double quote")
+			}
+		default:
+			word.WriteByte(c)
+		}
+		inWord = true
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// quoteShellWord quotes s, if needed, so that splitShellWords returns
+// s as a single word.
+func quoteShellWord(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\n\r\\'\"") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// joinShellWords joins words into a string split back into words by
+// splitShellWords.
+func joinShellWords(words []string) string {
+	quoted := make([]string, len(words))
+	for i, w := range words {
+		quoted[i] = quoteShellWord(w)
+	}
+	return strings.Join(quoted, " ")
+}
@@ -0,0 +1,216 @@
+package sflag
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// PrintVisibleDefaults is like fs.PrintDefaults but it doesn't print
+// the flags marked as hidden. As the flag package has no notion of
+// hidden flags, fs.Usage must be set to a function calling
+// PrintVisibleDefaults for the hidden flags to be omitted from the
+// usage message printed on parsing errors.
+func PrintVisibleDefaults(fs *flag.FlagSet) {
+	var hidden map[string]bool
+	readFlagSetInfo(fs, func(info *flagSetInfo) {
+		if info != nil {
+			hidden = make(map[string]bool, len(info.hidden))
+			for name := range info.hidden {
+				hidden[name] = true
+			}
+		}
+	})
+	visible := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+	visible.SetOutput(fs.Output())
+	fs.VisitAll(func(fl *flag.Flag) {
+		if hidden[fl.Name] {
+			return
+		}
+		copyFlag(visible, fl)
+	})
+	visible.PrintDefaults()
+}
+
+// DefaultGroup is the group of the flags without group marker, see
+// PrintGroupedDefaults.
+const DefaultGroup = "Options"
+
+// PrintGroupedDefaults is like PrintVisibleDefaults but it prints the
+// flags to w, organized under a header for each group given by the
+// group marker of the flags. The flags without group, including the
+// flags not added by the package, are printed first under the
+// DefaultGroup header, the other groups following in the order in
+// which their first flag was added. If w is nil, fs.Output() is used.
+func PrintGroupedDefaults(fs *flag.FlagSet, w io.Writer) {
+	if w == nil {
+		w = fs.Output()
+	}
+	var hidden map[string]bool
+	var groups map[string]string
+	order := []string{DefaultGroup}
+	readFlagSetInfo(fs, func(info *flagSetInfo) {
+		if info != nil {
+			hidden = make(map[string]bool, len(info.hidden))
+			for name := range info.hidden {
+				hidden[name] = true
+			}
+			groups = make(map[string]string, len(info.groups))
+			for name, group := range info.groups {
+				groups[name] = group
+			}
+			for _, group := range info.groupOrder {
+				if group != DefaultGroup {
+					order = append(order, group)
+				}
+			}
+		}
+	})
+	sets := make(map[string]*flag.FlagSet, len(order))
+	fs.VisitAll(func(fl *flag.Flag) {
+		if hidden[fl.Name] {
+			return
+		}
+		group := groups[fl.Name]
+		if group == "" {
+			group = DefaultGroup
+		}
+		gs := sets[group]
+		if gs == nil {
+			gs = flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+			gs.SetOutput(w)
+			sets[group] = gs
+		}
+		copyFlag(gs, fl)
+	})
+	first := true
+	for _, group := range order {
+		gs := sets[group]
+		if gs == nil {
+			continue
+		}
+		if !first {
+			fmt.Fprintln(w)
+		}
+		first = false
+		fmt.Fprintf(w, "%s:\n", group)
+		gs.PrintDefaults()
+	}
+}
+
+// PrintDefaultsInOrder is like PrintVisibleDefaults but it prints the
+// flags to w in the order in which they were added, i.e. in the order
+// of the declaration of their fields, instead of the lexicographical
+// order. The flags not added by the package follow in lexicographical
+// order. If some flags have a group marker, the flags are organized
+// under a header for each group like PrintGroupedDefaults does. If w is
+// nil, fs.Output() is used.
+func PrintDefaultsInOrder(fs *flag.FlagSet, w io.Writer) {
+	if w == nil {
+		w = fs.Output()
+	}
+	var hidden map[string]bool
+	var groups map[string]string
+	var names []string
+	order := []string{DefaultGroup}
+	readFlagSetInfo(fs, func(info *flagSetInfo) {
+		if info != nil {
+			hidden = make(map[string]bool, len(info.hidden))
+			for name := range info.hidden {
+				hidden[name] = true
+			}
+			groups = make(map[string]string, len(info.groups))
+			for name, group := range info.groups {
+				groups[name] = group
+			}
+			for _, group := range info.groupOrder {
+				if group != DefaultGroup {
+					order = append(order, group)
+				}
+			}
+			names = append(names, info.order...)
+		}
+	})
+	seen := make(map[string]bool, len(names))
+	var flags []*flag.Flag
+	for _, name := range names {
+		if fl := fs.Lookup(name); fl != nil && !seen[name] {
+			seen[name] = true
+			flags = append(flags, fl)
+		}
+	}
+	fs.VisitAll(func(fl *flag.Flag) {
+		if !seen[fl.Name] {
+			flags = append(flags, fl)
+		}
+	})
+	byGroup := make(map[string][]*flag.Flag, len(order))
+	for _, fl := range flags {
+		if hidden[fl.Name] {
+			continue
+		}
+		group := groups[fl.Name]
+		if group == "" {
+			group = DefaultGroup
+		}
+		byGroup[group] = append(byGroup[group], fl)
+	}
+	if len(groups) == 0 {
+		printFlags(fs, w, byGroup[DefaultGroup])
+		return
+	}
+	first := true
+	for _, group := range order {
+		if len(byGroup[group]) == 0 {
+			continue
+		}
+		if !first {
+			fmt.Fprintln(w)
+		}
+		first = false
+		fmt.Fprintf(w, "%s:\n", group)
+		printFlags(fs, w, byGroup[group])
+	}
+}
+
+// printFlags prints flags, the flags of fs, to w in the given order,
+// formatted like fs.PrintDefaults does.
+func printFlags(fs *flag.FlagSet, w io.Writer, flags []*flag.Flag) {
+	for _, fl := range flags {
+		// PrintDefaults sorts the flags, so each flag is printed
+		// by its own FlagSet
+		one := flag.NewFlagSet(fs.Name(), flag.ContinueOnError)
+		one.SetOutput(w)
+		copyFlag(one, fl)
+		one.PrintDefaults()
+	}
+}
+
+// copyFlag defines the flag fl in dst to print it. The value of fl is
+// unwrapped so that the type of a flag wrapped by a check, e.g. int
+// for a flag with a min marker, is printed like for a flag without
+// check.
+func copyFlag(dst *flag.FlagSet, fl *flag.Flag) {
+	dst.Var(unwrapValue(fl.Value), fl.Name, fl.Usage)
+	dst.Lookup(fl.Name).DefValue = fl.DefValue
+}
+
+// setUsage sets fs.Usage to print the usage header given by
+// WithUsageHeader, if any, once the flags are added to fs.
+func setUsage(fs *flag.FlagSet, o *options) {
+	if o.usageHeader != "" {
+		fs.Usage = headerUsage(fs, o.usageHeader)
+	}
+}
+
+// headerUsage returns a usage function for fs printing header followed
+// by the grouped flags, see WithUsageHeader.
+func headerUsage(fs *flag.FlagSet, header string) func() {
+	return func() {
+		w := fs.Output()
+		fmt.Fprintln(w, strings.TrimRight(header, "\n"))
+		fmt.Fprintln(w)
+		PrintGroupedDefaults(fs, w)
+	}
+}
@@ -0,0 +1,60 @@
+package sflag
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestPrintDefaultsTypeNames(t *testing.T) {
+	var s struct {
+		Port  int     `flag:"port,80,port"`
+		Size  uint    `flag:"size,0,size"`
+		Count int64   `flag:"count,0,count"`
+		Total uint64  `flag:"total,0,total"`
+		Level int     `flag:"level,1,level|min=1|max=3"`
+		Mode  string  `flag:"mode,a,mode|oneof=a,b"`
+		Ratio float64 `flag:"ratio,0,ratio"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := AddFlagsWith(fs, &s); err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	fs.SetOutput(&b)
+	fs.PrintDefaults()
+	for _, want := range []string{"-port int", "-size uint", "-count int", "-total uint", "-ratio float"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("PrintDefaults output doesn't contain %q:\n%s", want, &b)
+		}
+	}
+	b.Reset()
+	PrintVisibleDefaults(fs)
+	for _, want := range []string{"-port int", "-level int", "-mode string"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("PrintVisibleDefaults output doesn't contain %q:\n%s", want, &b)
+		}
+	}
+}
+
+func TestUsageHeader(t *testing.T) {
+	var s struct {
+		Port int `flag:"port,80,port"`
+	}
+	var empty struct{}
+	for _, v := range []any{&s, &empty} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		if err := AddFlagsWith(fs, v, WithUsageHeader("usage: test [flags]")); err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		fs.SetOutput(&b)
+		if err := fs.Parse([]string{"-help"}); err != flag.ErrHelp {
+			t.Fatalf("got error %v, want flag.ErrHelp", err)
+		}
+		if !strings.HasPrefix(b.String(), "usage: test [flags]\n") {
+			t.Errorf("%T: usage doesn't start with the header:\n%s", v, &b)
+		}
+	}
+}
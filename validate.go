@@ -0,0 +1,278 @@
+package sflag
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// checkedValue is a flag.Value checking the values given to its Set
+// method before passing them to the wrapped flag.Value. If normalize
+// is not nil, the checked values are replaced by their normalized form.
+type checkedValue struct {
+	flag.Value
+	check     func(s string) error
+	normalize func(s string) string
+}
+
+func (v *checkedValue) Set(s string) error {
+	if err := v.check(s); err != nil {
+		return err
+	}
+	if v.normalize != nil {
+		s = v.normalize(s)
+	}
+	return v.Value.Set(s)
+}
+
+func (v *checkedValue) String() string {
+	if v.Value == nil {
+		return ""
+	}
+	return v.Value.String()
+}
+
+func (v *checkedValue) setDefault(s string) error {
+	if err := v.check(s); err != nil {
+		return err
+	}
+	if v.normalize != nil {
+		s = v.normalize(s)
+	}
+	if ds, ok := v.Value.(defaultSetter); ok {
+		return ds.setDefault(s)
+	}
+	return v.Value.Set(s)
+}
+
+func (v *checkedValue) IsBoolFlag() bool {
+	return v.Value != nil && isBoolValue(v.Value)
+}
+
+func (v *checkedValue) unwrap() flag.Value {
+	return v.Value
+}
+
+// unwrapper is implemented by the values wrapping another value.
+type unwrapper interface {
+	unwrap() flag.Value
+}
+
+// unwrapValue returns the innermost value wrapped by v.
+func unwrapValue(v flag.Value) flag.Value {
+	for {
+		u, ok := v.(unwrapper)
+		if !ok {
+			return v
+		}
+		v = u.unwrap()
+	}
+}
+
+// addChecks wraps the value of fl to enforce the constraints given by
+// the markers of ft, typ being the type of the field from which fl is
+// derived.
+func addChecks(fl *flag.Flag, typ reflect.Type, ft *flagTag) error {
+	oneof, hasOneOf := ft.markers["oneof"]
+	oneofci, hasOneOfCI := ft.markers["oneofci"]
+	if hasOneOf && hasOneOfCI {
+		return errors.New("oneof and oneofci markers are mutually exclusive")
+	}
+	if hasOneOf {
+		_, ignoreCase := ft.markers["ignorecase"]
+		fl.Value = &checkedValue{Value: fl.Value, check: checkOneOf(strings.Split(oneof, ","), ignoreCase)}
+	}
+	if hasOneOfCI {
+		allowed := strings.Split(oneofci, ",")
+		fl.Value = &checkedValue{
+			Value:     fl.Value,
+			check:     checkOneOf(allowed, true),
+			normalize: canonicalOneOf(allowed),
+		}
+	}
+	min, hasMin := ft.markers["min"]
+	max, hasMax := ft.markers["max"]
+	if hasMin || hasMax {
+		check, err := checkRange(typ, ft, min, max)
+		if err != nil {
+			return err
+		}
+		fl.Value = &checkedValue{Value: fl.Value, check: check}
+	}
+	return nil
+}
+
+// checkOneOf returns a function checking that a value is one of
+// allowed.
+func checkOneOf(allowed []string, ignoreCase bool) func(s string) error {
+	return func(s string) error {
+		for _, a := range allowed {
+			if s == a || ignoreCase && strings.EqualFold(s, a) {
+				return nil
+			}
+		}
+		return fmt.Errorf("%q is not one of %s", s, strings.Join(allowed, ", "))
+	}
+}
+
+// canonicalOneOf returns a function replacing a value by the first of
+// allowed equal to it ignoring case.
+func canonicalOneOf(allowed []string) func(s string) string {
+	return func(s string) string {
+		for _, a := range allowed {
+			if strings.EqualFold(s, a) {
+				return a
+			}
+		}
+		return s
+	}
+}
+
+// numberParser returns a function parsing the values of a flag of
+// type typ and tag ft as numbers.
+func numberParser(typ reflect.Type, ft *flagTag) (func(s string) (*big.Float, error), error) {
+	n := func() *big.Float {
+		return new(big.Float).SetPrec(128)
+	}
+	if _, ok := ft.markers["count"]; ok {
+		return nil, errors.New("range not supported for count flags")
+	}
+	if _, ok := ft.markers["bytesize"]; ok {
+		return func(s string) (*big.Float, error) {
+			b, err := parseByteSize(s)
+			if err != nil {
+				return nil, err
+			}
+			return n().SetUint64(b), nil
+		}, nil
+	}
+	if _, ok := ft.markers["rune"]; ok {
+		return func(s string) (*big.Float, error) {
+			r, err := parseRune(s)
+			if err != nil {
+				return nil, err
+			}
+			return n().SetInt64(int64(r)), nil
+		}, nil
+	}
+	if isDuration(typ, ft) {
+		unit, _ := durationUnit(ft)
+		return func(s string) (*big.Float, error) {
+			d, err := parseDuration(s, unit)
+			if err != nil {
+				return nil, err
+			}
+			return n().SetInt64(int64(d)), nil
+		}, nil
+	}
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(s string) (*big.Float, error) {
+			i, err := strconv.ParseInt(s, 0, 64)
+			if err != nil {
+				return nil, numError(err)
+			}
+			return n().SetInt64(i), nil
+		}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(s string) (*big.Float, error) {
+			u, err := strconv.ParseUint(s, 0, 64)
+			if err != nil {
+				return nil, numError(err)
+			}
+			return n().SetUint64(u), nil
+		}, nil
+	case reflect.Float32, reflect.Float64:
+		return func(s string) (*big.Float, error) {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, numError(err)
+			}
+			return n().SetFloat64(f), nil
+		}, nil
+	}
+	return nil, fmt.Errorf("range not supported for type %q", typ)
+}
+
+// checkRange returns a function checking that a value is in the range
+// [min, max], typ being the type of the field and ft its tag. An empty
+// bound is not checked, except the lower bound of unsigned integers
+// which defaults to 0.
+func checkRange(typ reflect.Type, ft *flagTag, min string, max string) (func(s string) error, error) {
+	parse, err := numberParser(typ, ft)
+	if err != nil {
+		return nil, err
+	}
+	var lo, hi *big.Float
+	if min != "" {
+		if lo, err = parse(min); err != nil {
+			return nil, fmt.Errorf("invalid min %q: %v", min, err)
+		}
+	}
+	if max != "" {
+		if hi, err = parse(max); err != nil {
+			return nil, fmt.Errorf("invalid max %q: %v", max, err)
+		}
+	}
+	rng := formatRange(typ, min, max)
+	return func(s string) error {
+		x, err := parse(s)
+		if err != nil {
+			return err
+		}
+		if lo != nil && x.Cmp(lo) < 0 || hi != nil && x.Cmp(hi) > 0 {
+			return fmt.Errorf("%s is out of range %s", s, rng)
+		}
+		return nil
+	}, nil
+}
+
+// formatRange formats the range [min, max] of the values of a field
+// of type typ, an empty bound being infinite, except the lower bound
+// of unsigned integers which is 0.
+func formatRange(typ reflect.Type, min string, max string) string {
+	switch typ.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if min == "" {
+			min = "0"
+		}
+	}
+	rng := "(-inf, "
+	if min != "" {
+		rng = "[" + min + ", "
+	}
+	if max != "" {
+		rng += max + "]"
+	} else {
+		rng += "+inf)"
+	}
+	return rng
+}
+
+// annotateHelp returns help followed by the constraints given by the
+// markers of ft, typ being the type of the field.
+func annotateHelp(help string, typ reflect.Type, ft *flagTag) string {
+	var notes []string
+	if oneof, ok := ft.markers["oneof"]; ok {
+		notes = append(notes, "allowed: "+strings.ReplaceAll(oneof, ",", "|"))
+	}
+	if oneof, ok := ft.markers["oneofci"]; ok {
+		notes = append(notes, "allowed: "+strings.ReplaceAll(oneof, ",", "|")+", case-insensitive")
+	}
+	min, hasMin := ft.markers["min"]
+	max, hasMax := ft.markers["max"]
+	if hasMin || hasMax {
+		notes = append(notes, "range: "+formatRange(typ, min, max))
+	}
+	for _, note := range notes {
+		if help != "" {
+			help += " "
+		}
+		help += "(" + note + ")"
+	}
+	return help
+}
@@ -0,0 +1,1151 @@
+package sflag
+
+import (
+	"encoding"
+	"encoding/base64"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var flagValueType = reflect.TypeOf((*flag.Value)(nil)).Elem()
+
+var (
+	typesMu sync.RWMutex
+	types   = make(map[reflect.Type]func(deflt string, help string) flag.Value)
+)
+
+// RegisterType registers fn as the handler of the fields of type t.
+// The flags derived from these fields use the flag.Value returned by
+// fn, deflt being the default value given in the field tag and help
+// the help message of the flag. The returned flag.Value must already
+// hold the default value. It should implement flag.Getter, the value
+// returned by Get being assigned to the field. If this value can't be
+// assigned and the pointer to the field implements flag.Value, the
+// field parses the string representation of the flag instead. The
+// registered handlers take precedence over the types supported by the
+// package, including the types implementing flag.Value. A pointer
+// field uses the handler registered for its element type unless a
+// handler is registered for the pointer type itself.
+func RegisterType(t reflect.Type, fn func(deflt string, help string) flag.Value) {
+	typesMu.Lock()
+	defer typesMu.Unlock()
+	types[t] = fn
+	clearFieldCache()
+}
+
+// lookupType returns the handler registered for typ or for its
+// element type if typ is a pointer.
+func lookupType(typ reflect.Type) func(deflt string, help string) flag.Value {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+	if fn := types[typ]; fn != nil {
+		return fn
+	}
+	if typ.Kind() == reflect.Pointer {
+		return types[typ.Elem()]
+	}
+	return nil
+}
+
+// defaultSetter is implemented by the values that must distinguish
+// the default value of a flag from the values given on the command
+// line. Successive calls to setDefault accumulate the values.
+type defaultSetter interface {
+	setDefault(s string) error
+}
+
+func numError(err error) error {
+	var ne *strconv.NumError
+	if errors.As(err, &ne) {
+		return ne.Err
+	}
+	return err
+}
+
+// canParseElem reports whether values of type typ can be parsed by
+// parseElem.
+func canParseElem(typ reflect.Type) bool {
+	if reflect.PointerTo(typ).Implements(flagValueType) {
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	}
+	return false
+}
+
+// parseElem parses s as a value of type typ. Integers are parsed
+// using base 0, like the flag package does, durations using
+// time.ParseDuration and the types whose pointer implements
+// flag.Value using the Set method of a new value.
+func parseElem(typ reflect.Type, s string) (reflect.Value, error) {
+	if reflect.PointerTo(typ).Implements(flagValueType) {
+		pv := reflect.New(typ)
+		if err := pv.Interface().(flag.Value).Set(s); err != nil {
+			return pv.Elem(), err
+		}
+		return pv.Elem(), nil
+	}
+	v := reflect.New(typ).Elem()
+	if typ == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return v, err
+		}
+		v.SetInt(int64(d))
+		return v, nil
+	}
+	switch typ.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return v, numError(err)
+		}
+		v.SetBool(b)
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 0, typ.Bits())
+		if err != nil {
+			return v, numError(err)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 0, typ.Bits())
+		if err != nil {
+			return v, numError(err)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, typ.Bits())
+		if err != nil {
+			return v, numError(err)
+		}
+		v.SetFloat(f)
+	case reflect.Complex64, reflect.Complex128:
+		c, err := strconv.ParseComplex(s, typ.Bits())
+		if err != nil {
+			return v, numError(err)
+		}
+		v.SetComplex(c)
+	default:
+		return v, fmt.Errorf("unsupported type %q", typ)
+	}
+	return v, nil
+}
+
+// newTypeValue returns a flag.Value for typ if typ is one of the
+// types not implementing flag.Value but specifically supported by
+// the package. It returns nil otherwise.
+func newTypeValue(typ reflect.Type, ft *flagTag) (flag.Value, error) {
+	switch typ {
+	case reflect.TypeOf(big.Int{}):
+		base, err := bigIntBase(ft)
+		if err != nil {
+			return nil, err
+		}
+		return &bigIntValue{base: base}, nil
+	case reflect.TypeOf(time.Time{}):
+		layout := ft.markers["layout"]
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		return &timeValue{layout: layout}, nil
+	case reflect.TypeOf(net.IP{}):
+		return &ipValue{}, nil
+	case reflect.TypeOf(net.IPNet{}):
+		return &ipNetValue{}, nil
+	case reflect.TypeOf(url.URL{}):
+		return &urlValue{}, nil
+	case reflect.TypeOf(netip.Addr{}):
+		return &addrValue{}, nil
+	case reflect.TypeOf(netip.Prefix{}):
+		return &prefixValue{}, nil
+	case reflect.TypeOf(netip.AddrPort{}):
+		return &addrPortValue{}, nil
+	}
+	return nil, nil
+}
+
+// isValueType reports whether typ, a non pointer type, is turned into
+// a flag by a dedicated flag.Value regardless of its kind.
+func isValueType(typ reflect.Type) bool {
+	tv, _ := newTypeValue(typ, &flagTag{})
+	return lookupType(typ) != nil || reflect.PointerTo(typ).Implements(flagValueType) || tv != nil || isTextType(typ)
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// isTextType reports whether typ, a non pointer type, can be parsed
+// using its UnmarshalText method.
+func isTextType(typ reflect.Type) bool {
+	return reflect.PointerTo(typ).Implements(textUnmarshalerType)
+}
+
+// textValue is a flag.Value parsing a value implementing
+// encoding.TextUnmarshaler, the value being formatted using its
+// MarshalText method if any.
+type textValue struct {
+	v reflect.Value
+}
+
+func newTextValue(typ reflect.Type) *textValue {
+	return &textValue{reflect.New(typ).Elem()}
+}
+
+func (v *textValue) Set(s string) error {
+	pv := reflect.New(v.v.Type())
+	if err := pv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s)); err != nil {
+		return err
+	}
+	v.v = pv.Elem()
+	return nil
+}
+
+func (v *textValue) String() string {
+	if v == nil || !v.v.IsValid() {
+		return ""
+	}
+	return formatText(v.v)
+}
+
+func (v *textValue) Get() any {
+	return v.v.Interface()
+}
+
+// IsBoolFlag reports whether the parsed type is itself a boolean flag
+// type, i.e. its pointer implements an IsBoolFlag method returning
+// true, so that the flag can be given without value.
+func (v *textValue) IsBoolFlag() bool {
+	if v == nil || !v.v.IsValid() {
+		return false
+	}
+	bv, ok := reflect.New(v.v.Type()).Interface().(interface{ IsBoolFlag() bool })
+	return ok && bv.IsBoolFlag()
+}
+
+// formatText formats v using its MarshalText method if any, falling
+// back to formatValue.
+func formatText(v reflect.Value) string {
+	pv := reflect.New(v.Type())
+	pv.Elem().Set(v)
+	if m, ok := pv.Interface().(encoding.TextMarshaler); ok {
+		if b, err := m.MarshalText(); err == nil {
+			return string(b)
+		}
+	}
+	return formatValue(v)
+}
+
+// isSupportedType reports whether a field of type typ can be turned
+// into a flag without marker.
+func isSupportedType(typ reflect.Type) bool {
+	if lookupType(typ) != nil {
+		return true
+	}
+	if typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if isValueType(typ) {
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.Slice:
+		return canParseElem(typ.Elem())
+	case reflect.Map:
+		_, err := newMapValue(typ, "", nil)
+		return err == nil
+	case reflect.Array:
+		return canParseElem(typ.Elem())
+	case reflect.Struct, reflect.Chan, reflect.Func, reflect.Interface, reflect.Pointer, reflect.UnsafePointer, reflect.Uintptr, reflect.Invalid:
+		return false
+	}
+	return true
+}
+
+// canConvert reports whether a value of type from can be converted to
+// the type to. Unlike reflect.Type.ConvertibleTo, an integer can't be
+// converted to a string, such a conversion giving a character, which
+// is never what is meant.
+func canConvert(from reflect.Type, to reflect.Type) bool {
+	if to.Kind() == reflect.String && from.Kind() != reflect.String {
+		return false
+	}
+	return from.ConvertibleTo(to)
+}
+
+// overflows reports whether the numeric value v doesn't fit in the
+// numeric type typ. It returns false if either v or typ isn't numeric.
+func overflows(v reflect.Value, typ reflect.Type) bool {
+	z := reflect.Zero(typ)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch typ.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return z.OverflowInt(v.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return v.Int() < 0 || z.OverflowUint(uint64(v.Int()))
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		switch typ.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			return v.Uint() > math.MaxInt64 || z.OverflowInt(int64(v.Uint()))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			return z.OverflowUint(v.Uint())
+		}
+	case reflect.Float32, reflect.Float64:
+		switch typ.Kind() {
+		case reflect.Float32, reflect.Float64:
+			return z.OverflowFloat(v.Float())
+		}
+	}
+	return false
+}
+
+// isDuration reports whether the fields of type typ and tag ft are
+// parsed as a time.Duration.
+func isDuration(typ reflect.Type, ft *flagTag) bool {
+	if typ == reflect.TypeOf(time.Duration(0)) {
+		return true
+	}
+	_, ok := ft.markers["duration"]
+	return ok && typ.Kind() == reflect.Int64
+}
+
+// durationUnit returns the unit given by the unit marker of ft, 0 if
+// there is no such marker.
+func durationUnit(ft *flagTag) (time.Duration, error) {
+	u, ok := ft.markers["unit"]
+	if !ok {
+		return 0, nil
+	}
+	unit, err := time.ParseDuration("1" + u)
+	if err != nil || u == "" {
+		return 0, fmt.Errorf("invalid duration unit %q", u)
+	}
+	return unit, nil
+}
+
+// parseDuration parses s using time.ParseDuration. If unit is not 0,
+// a bare number, e.g. "30" or "1.5", is a number of unit.
+func parseDuration(s string, unit time.Duration) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err == nil || unit == 0 {
+		return d, err
+	}
+	f, ferr := strconv.ParseFloat(s, 64)
+	if ferr != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, err
+	}
+	f *= float64(unit)
+	if f >= math.MaxInt64 || f < math.MinInt64 {
+		return 0, fmt.Errorf("duration %q out of range", s)
+	}
+	return time.Duration(f), nil
+}
+
+// durationValue is a flag.Value parsing a duration using
+// parseDuration with the unit unit. typ is the type of the value
+// returned by Get.
+type durationValue struct {
+	d    time.Duration
+	typ  reflect.Type
+	unit time.Duration
+}
+
+func (v *durationValue) Set(s string) error {
+	d, err := parseDuration(s, v.unit)
+	if err != nil {
+		return err
+	}
+	v.d = d
+	return nil
+}
+
+func (v *durationValue) String() string {
+	if v == nil {
+		return "0s"
+	}
+	return v.d.String()
+}
+
+func (v *durationValue) Get() any {
+	return reflect.ValueOf(v.d).Convert(v.typ).Interface()
+}
+
+// formatField returns the strings that must be given, in order, to
+// the Set method of the value of the flag derived from a field of tag
+// ft so that the value of the flag matches v, the value of the field.
+func formatField(v reflect.Value, ft *flagTag) []string {
+	if v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	typ := v.Type()
+	if enc := binaryEncodings[ft.markers["binary"]]; enc != nil {
+		return []string{formatBinary(v, enc)}
+	}
+	if tv, _ := newTypeValue(typ, ft); lookupType(typ) != nil || reflect.PointerTo(typ).Implements(flagValueType) || tv != nil {
+		if bv, ok := tv.(*bigIntValue); ok {
+			i := v.Interface().(big.Int)
+			return []string{bv.format(&i)}
+		}
+		if typ == reflect.TypeOf(time.Time{}) {
+			layout := ft.markers["layout"]
+			if layout == "" {
+				layout = time.RFC3339
+			}
+			return []string{v.Interface().(time.Time).Format(layout)}
+		}
+		switch tv.(type) {
+		case *addrValue, *prefixValue, *addrPortValue:
+			// The zero values are formatted as an empty string
+			return []string{formatText(v)}
+		}
+		return []string{formatValue(v)}
+	}
+	if isTextType(typ) {
+		return []string{formatText(v)}
+	}
+	switch typ.Kind() {
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			if enc := byteEncodings[ft.markers["encoding"]]; enc != nil {
+				return []string{enc.EncodeToString(v.Bytes())}
+			}
+		}
+		strs := make([]string, v.Len())
+		for i := range strs {
+			strs[i] = formatValue(v.Index(i))
+			if _, ok := ft.markers["shellwords"]; ok {
+				strs[i] = quoteShellWord(strs[i])
+			}
+		}
+		return strs
+	case reflect.Array:
+		return []string{formatArray(v, ft.markers["sep"])}
+	case reflect.Map:
+		strs := make([]string, 0, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			strs = append(strs, formatValue(iter.Key())+"="+formatValue(iter.Value()))
+		}
+		sort.Strings(strs)
+		return strs
+	}
+	if isDuration(typ, ft) {
+		return []string{time.Duration(v.Int()).String()}
+	}
+	if _, ok := ft.markers["rune"]; ok && typ.Kind() == reflect.Int32 {
+		return []string{string(rune(v.Int()))}
+	}
+	if _, ok := ft.markers["bytesize"]; ok {
+		switch typ.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if v.Int() >= 0 {
+				return []string{formatByteSize(uint64(v.Int()))}
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return []string{formatByteSize(v.Uint())}
+		}
+	}
+	return []string{formatValue(v)}
+}
+
+// formatValue returns the string representation of v. The String
+// method of v is used if v implements fmt.Stringer, with either a
+// value or a pointer receiver, except for the basic kinds which are
+// formatted according to their kind, time.Duration excepted.
+func formatValue(v reflect.Value) string {
+	typ := v.Type()
+	switch typ.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if typ == reflect.TypeOf(time.Duration(0)) {
+			return time.Duration(v.Int()).String()
+		}
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'g', -1, typ.Bits())
+	case reflect.Complex64, reflect.Complex128:
+		return strconv.FormatComplex(v.Complex(), 'g', -1, typ.Bits())
+	case reflect.String:
+		return v.String()
+	}
+	pv := reflect.New(typ)
+	pv.Elem().Set(v)
+	if s, ok := pv.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// timeValue is a flag.Value parsing a time.Time using layout.
+type timeValue struct {
+	t      time.Time
+	layout string
+}
+
+func (v *timeValue) Set(s string) error {
+	t, err := time.Parse(v.layout, s)
+	if err != nil {
+		return err
+	}
+	v.t = t
+	return nil
+}
+
+func (v *timeValue) String() string {
+	if v.t.IsZero() {
+		return ""
+	}
+	return v.t.Format(v.layout)
+}
+
+func (v *timeValue) Get() any {
+	return v.t
+}
+
+// complexValue is a flag.Value parsing a complex number using
+// strconv.ParseComplex. typ is either a complex64 or a complex128
+// kind, the value returned by Get being of this type.
+type complexValue struct {
+	c   complex128
+	typ reflect.Type
+}
+
+func (v *complexValue) Set(s string) error {
+	c, err := strconv.ParseComplex(s, v.typ.Bits())
+	if err != nil {
+		return numError(err)
+	}
+	v.c = c
+	return nil
+}
+
+func (v *complexValue) String() string {
+	if v.typ == nil {
+		return "(0+0i)"
+	}
+	return strconv.FormatComplex(v.c, 'g', -1, v.typ.Bits())
+}
+
+func (v *complexValue) Get() any {
+	return reflect.ValueOf(v.c).Convert(v.typ).Interface()
+}
+
+// countValue is a boolean flag.Value counting the number of times
+// the flag is given on the command line. An explicit integer value,
+// e.g. -v=3, sets the count. typ is the integer type of the value
+// returned by Get.
+type countValue struct {
+	n   int64
+	typ reflect.Type
+}
+
+func newCountValue(typ reflect.Type) (*countValue, error) {
+	switch typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &countValue{typ: typ}, nil
+	}
+	return nil, fmt.Errorf("count flags require an integer type")
+}
+
+func (v *countValue) Set(s string) error {
+	var n int64
+	switch s {
+	case "true":
+		n = v.n + 1
+	case "false":
+	default:
+		var err error
+		if n, err = strconv.ParseInt(s, 0, 64); err != nil {
+			return numError(err)
+		}
+	}
+	z := reflect.New(v.typ).Elem()
+	switch v.typ.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if z.OverflowInt(n) {
+			return fmt.Errorf("count %d out of range for %s", n, v.typ)
+		}
+	default:
+		if n < 0 || z.OverflowUint(uint64(n)) {
+			return fmt.Errorf("count %d out of range for %s", n, v.typ)
+		}
+	}
+	v.n = n
+	return nil
+}
+
+func (v *countValue) String() string {
+	return strconv.FormatInt(v.n, 10)
+}
+
+func (v *countValue) Get() any {
+	return reflect.ValueOf(v.n).Convert(v.typ).Interface()
+}
+
+func (v *countValue) IsBoolFlag() bool {
+	return true
+}
+
+// negatedValue is a boolean flag.Value setting the boolean value b
+// to the negation of its own value.
+type negatedValue struct {
+	b flag.Value
+}
+
+func (v *negatedValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return numError(err)
+	}
+	return v.b.Set(strconv.FormatBool(!b))
+}
+
+func (v *negatedValue) String() string {
+	if v.b == nil {
+		return "false"
+	}
+	b, _ := strconv.ParseBool(v.b.String())
+	return strconv.FormatBool(!b)
+}
+
+func (v *negatedValue) Get() any {
+	if getter, ok := unwrapValue(v.b).(flag.Getter); ok {
+		return getter.Get()
+	}
+	b, _ := strconv.ParseBool(v.b.String())
+	return b
+}
+
+func (v *negatedValue) IsBoolFlag() bool {
+	return true
+}
+
+// bigIntBase returns the base given by the base marker of ft, 0 if
+// there is no such marker.
+func bigIntBase(ft *flagTag) (int, error) {
+	b, ok := ft.markers["base"]
+	if !ok {
+		return 0, nil
+	}
+	base, err := strconv.Atoi(b)
+	if err != nil || base != 0 && (base < 2 || base > big.MaxBase) {
+		return 0, fmt.Errorf("invalid base %q", b)
+	}
+	return base, nil
+}
+
+// bigIntValue is a flag.Value parsing a big.Int in the given base. A
+// base of 0 accepts the prefixes 0b, 0o and 0x like the integer flags.
+type bigIntValue struct {
+	i    *big.Int
+	base int
+}
+
+func (v *bigIntValue) Set(s string) error {
+	i, ok := new(big.Int).SetString(s, v.base)
+	if !ok {
+		return fmt.Errorf("invalid integer %q", s)
+	}
+	v.i = i
+	return nil
+}
+
+func (v *bigIntValue) String() string {
+	if v == nil || v.i == nil {
+		return ""
+	}
+	return v.format(v.i)
+}
+
+// format formats i so that it can be parsed back by Set.
+func (v *bigIntValue) format(i *big.Int) string {
+	if v.base == 0 {
+		return i.String()
+	}
+	return i.Text(v.base)
+}
+
+func (v *bigIntValue) Get() any {
+	if v.i == nil {
+		return new(big.Int)
+	}
+	return v.i
+}
+
+// ipValue is a flag.Value parsing a net.IP using net.ParseIP.
+type ipValue struct {
+	ip net.IP
+}
+
+func (v *ipValue) Set(s string) error {
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return fmt.Errorf("invalid IP address %q", s)
+	}
+	v.ip = ip
+	return nil
+}
+
+func (v *ipValue) String() string {
+	if v.ip == nil {
+		return ""
+	}
+	return v.ip.String()
+}
+
+func (v *ipValue) Get() any {
+	return v.ip
+}
+
+// ipNetValue is a flag.Value parsing a net.IPNet using
+// net.ParseCIDR.
+type ipNetValue struct {
+	n net.IPNet
+}
+
+func (v *ipNetValue) Set(s string) error {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		return err
+	}
+	v.n = *n
+	return nil
+}
+
+func (v *ipNetValue) String() string {
+	if v.n.IP == nil {
+		return ""
+	}
+	return v.n.String()
+}
+
+func (v *ipNetValue) Get() any {
+	return v.n
+}
+
+// urlValue is a flag.Value parsing a url.URL using url.Parse.
+type urlValue struct {
+	u url.URL
+}
+
+func (v *urlValue) Set(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return err
+	}
+	v.u = *u
+	return nil
+}
+
+func (v *urlValue) String() string {
+	return v.u.String()
+}
+
+func (v *urlValue) Get() any {
+	return v.u
+}
+
+// addrValue is a flag.Value parsing a netip.Addr using
+// netip.ParseAddr.
+type addrValue struct {
+	a netip.Addr
+}
+
+func (v *addrValue) Set(s string) error {
+	a, err := netip.ParseAddr(s)
+	if err != nil {
+		return err
+	}
+	v.a = a
+	return nil
+}
+
+func (v *addrValue) String() string {
+	if !v.a.IsValid() {
+		return ""
+	}
+	return v.a.String()
+}
+
+func (v *addrValue) Get() any {
+	return v.a
+}
+
+// prefixValue is a flag.Value parsing a netip.Prefix using
+// netip.ParsePrefix.
+type prefixValue struct {
+	p netip.Prefix
+}
+
+func (v *prefixValue) Set(s string) error {
+	p, err := netip.ParsePrefix(s)
+	if err != nil {
+		return err
+	}
+	v.p = p
+	return nil
+}
+
+func (v *prefixValue) String() string {
+	if !v.p.IsValid() {
+		return ""
+	}
+	return v.p.String()
+}
+
+func (v *prefixValue) Get() any {
+	return v.p
+}
+
+// addrPortValue is a flag.Value parsing a netip.AddrPort using
+// netip.ParseAddrPort.
+type addrPortValue struct {
+	ap netip.AddrPort
+}
+
+func (v *addrPortValue) Set(s string) error {
+	ap, err := netip.ParseAddrPort(s)
+	if err != nil {
+		return err
+	}
+	v.ap = ap
+	return nil
+}
+
+func (v *addrPortValue) String() string {
+	if !v.ap.IsValid() {
+		return ""
+	}
+	return v.ap.String()
+}
+
+func (v *addrPortValue) Get() any {
+	return v.ap
+}
+
+// byteEncodings maps the values of the encoding marker to the base64
+// encoding they stand for.
+var byteEncodings = map[string]*base64.Encoding{
+	"":       base64.StdEncoding,
+	"std":    base64.StdEncoding,
+	"url":    base64.URLEncoding,
+	"rawstd": base64.RawStdEncoding,
+	"rawurl": base64.RawURLEncoding,
+}
+
+// bytesValue is a flag.Value parsing a base64 encoded byte slice.
+type bytesValue struct {
+	b   reflect.Value
+	enc *base64.Encoding
+}
+
+func newBytesValue(typ reflect.Type, encoding string) (*bytesValue, error) {
+	enc := byteEncodings[encoding]
+	if enc == nil {
+		return nil, fmt.Errorf("unknown encoding %q", encoding)
+	}
+	return &bytesValue{b: reflect.New(typ).Elem(), enc: enc}, nil
+}
+
+func (v *bytesValue) Set(s string) error {
+	b, err := v.enc.DecodeString(s)
+	if err != nil {
+		return err
+	}
+	v.b = reflect.ValueOf(b).Convert(v.b.Type())
+	return nil
+}
+
+func (v *bytesValue) String() string {
+	if v == nil || !v.b.IsValid() {
+		return ""
+	}
+	return v.enc.EncodeToString(v.b.Bytes())
+}
+
+func (v *bytesValue) Get() any {
+	return v.b.Interface()
+}
+
+// arrayValue is a flag.Value parsing a fixed size array from a list
+// of elements separated by sep, the number of elements having to
+// match the length of the array.
+type arrayValue struct {
+	a   reflect.Value
+	sep string
+}
+
+func newArrayValue(typ reflect.Type, sep string) (*arrayValue, error) {
+	if !canParseElem(typ.Elem()) {
+		return nil, fmt.Errorf("unsupported array element type %q", typ.Elem())
+	}
+	if sep == "" {
+		sep = ","
+	}
+	return &arrayValue{a: reflect.New(typ).Elem(), sep: sep}, nil
+}
+
+func (v *arrayValue) Set(s string) error {
+	items := strings.Split(s, v.sep)
+	if len(items) != v.a.Len() {
+		return fmt.Errorf("%d elements expected, got %d", v.a.Len(), len(items))
+	}
+	a := reflect.New(v.a.Type()).Elem()
+	for i, item := range items {
+		elem, err := parseElem(a.Type().Elem(), item)
+		if err != nil {
+			return err
+		}
+		a.Index(i).Set(elem)
+	}
+	v.a = a
+	return nil
+}
+
+func (v *arrayValue) String() string {
+	if v == nil || !v.a.IsValid() || v.a.IsZero() {
+		return ""
+	}
+	return formatArray(v.a, v.sep)
+}
+
+func (v *arrayValue) Get() any {
+	return v.a.Interface()
+}
+
+// formatArray formats the elements of the array v separated by sep,
+// "," if empty.
+func formatArray(v reflect.Value, sep string) string {
+	if sep == "" {
+		sep = ","
+	}
+	strs := make([]string, v.Len())
+	for i := range strs {
+		strs[i] = formatValue(v.Index(i))
+	}
+	return strings.Join(strs, sep)
+}
+
+// sliceValue is a flag.Value accumulating the values of a repeated
+// flag into a slice. If sep is not empty, each value is split on sep
+// before being appended. If shell is true, each value is split into
+// words by splitShellWords instead. The default value, if any, is
+// replaced by the first value given on the command line.
+type sliceValue struct {
+	s     reflect.Value
+	sep   string
+	shell bool
+	deflt bool
+}
+
+func newSliceValue(typ reflect.Type, sep string, shell bool) (*sliceValue, error) {
+	if !canParseElem(typ.Elem()) {
+		return nil, fmt.Errorf("unsupported slice element type %q", typ.Elem())
+	}
+	return &sliceValue{s: reflect.New(typ).Elem(), sep: sep, shell: shell}, nil
+}
+
+func (v *sliceValue) Set(s string) error {
+	if v.deflt {
+		v.s.SetLen(0)
+		v.deflt = false
+	}
+	items := []string{s}
+	switch {
+	case v.shell:
+		var err error
+		if items, err = splitShellWords(s); err != nil {
+			return err
+		}
+	case v.sep != "":
+		if s == "" {
+			return nil
+		}
+		items = strings.Split(s, v.sep)
+	}
+	elems := make([]reflect.Value, len(items))
+	for i, item := range items {
+		elem, err := parseElem(v.s.Type().Elem(), item)
+		if err != nil {
+			return err
+		}
+		elems[i] = elem
+	}
+	v.s.Set(reflect.Append(v.s, elems...))
+	return nil
+}
+
+func (v *sliceValue) setDefault(s string) error {
+	v.deflt = false
+	if err := v.Set(s); err != nil {
+		return err
+	}
+	v.deflt = true
+	return nil
+}
+
+func (v *sliceValue) String() string {
+	if !v.s.IsValid() {
+		return ""
+	}
+	elems := make([]string, v.s.Len())
+	for i := range elems {
+		elems[i] = formatValue(v.s.Index(i))
+	}
+	if v.shell {
+		return joinShellWords(elems)
+	}
+	sep := v.sep
+	if sep == "" {
+		sep = ","
+	}
+	return strings.Join(elems, sep)
+}
+
+// Get returns a copy of the slice, so that the fields set from the
+// flag don't share its backing array.
+func (v *sliceValue) Get() any {
+	if v.s.IsNil() {
+		return v.s.Interface()
+	}
+	return reflect.AppendSlice(reflect.MakeSlice(v.s.Type(), 0, v.s.Len()), v.s).Interface()
+}
+
+// mapValue is a flag.Value accumulating the key=value pairs given by
+// the occurrences of the flag, the keys and the values being parsed
+// according to the key and element types of the map using parseElem.
+// If sep is not empty, each occurrence is split on sep into several
+// pairs. The default value, if any, is replaced by the first value
+// given on the command line. If parse is not nil, it parses the
+// values instead of parseElem, e.g. for a map of structs.
+type mapValue struct {
+	m     reflect.Value
+	sep   string
+	parse func(s string) (any, error)
+	deflt bool
+}
+
+func newMapValue(typ reflect.Type, sep string, parse func(s string) (any, error)) (*mapValue, error) {
+	if !canParseElem(typ.Key()) || parse == nil && !canParseElem(typ.Elem()) {
+		return nil, fmt.Errorf("unsupported map type %q", typ)
+	}
+	return &mapValue{m: reflect.MakeMap(typ), sep: sep, parse: parse}, nil
+}
+
+// parseValue parses s, the value of an entry of the map.
+func (v *mapValue) parseValue(s string) (reflect.Value, error) {
+	typ := v.m.Type().Elem()
+	if v.parse == nil {
+		return parseElem(typ, s)
+	}
+	x, err := v.parse(s)
+	if err != nil {
+		return reflect.Value{}, err
+	}
+	e := reflect.ValueOf(x)
+	if !e.IsValid() || !e.Type().AssignableTo(typ) {
+		return reflect.Value{}, fmt.Errorf("parsed value of type %T can't be assigned to map values of type %q", x, typ)
+	}
+	return e, nil
+}
+
+func (v *mapValue) Set(s string) error {
+	entries := []string{s}
+	if v.sep != "" {
+		if s == "" {
+			return nil
+		}
+		entries = strings.Split(s, v.sep)
+	}
+	keys := make([]reflect.Value, len(entries))
+	elems := make([]reflect.Value, len(entries))
+	for i, entry := range entries {
+		key, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid map entry %q, expected key=value", entry)
+		}
+		k, err := parseElem(v.m.Type().Key(), key)
+		if err != nil {
+			return fmt.Errorf("invalid key %q: %w", key, err)
+		}
+		e, err := v.parseValue(value)
+		if err != nil {
+			return fmt.Errorf("invalid value %q for key %q: %w", value, key, err)
+		}
+		keys[i], elems[i] = k, e
+	}
+	if v.deflt {
+		v.m = reflect.MakeMap(v.m.Type())
+		v.deflt = false
+	}
+	for i := range keys {
+		v.m.SetMapIndex(keys[i], elems[i])
+	}
+	return nil
+}
+
+func (v *mapValue) setDefault(s string) error {
+	v.deflt = false
+	if err := v.Set(s); err != nil {
+		return err
+	}
+	v.deflt = true
+	return nil
+}
+
+func (v *mapValue) String() string {
+	if !v.m.IsValid() {
+		return ""
+	}
+	entries := make([]string, 0, v.m.Len())
+	iter := v.m.MapRange()
+	for iter.Next() {
+		entries = append(entries, formatValue(iter.Key())+"="+formatValue(iter.Value()))
+	}
+	sort.Strings(entries)
+	sep := v.sep
+	if sep == "" {
+		sep = ","
+	}
+	return strings.Join(entries, sep)
+}
+
+// Get returns a copy of the map, so that the fields set from the flag
+// don't share its entries.
+func (v *mapValue) Get() any {
+	if v.m.IsNil() {
+		return v.m.Interface()
+	}
+	m := reflect.MakeMapWithSize(v.m.Type(), v.m.Len())
+	iter := v.m.MapRange()
+	for iter.Next() {
+		m.SetMapIndex(iter.Key(), iter.Value())
+	}
+	return m.Interface()
+}
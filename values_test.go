@@ -0,0 +1,567 @@
+package sflag
+
+import (
+	"flag"
+	"fmt"
+	"math/big"
+	"net/netip"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCountRange(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    uint8
+		wantErr bool
+	}{
+		{args: []string{"-v", "-v"}, want: 2},
+		{args: []string{"-v=255"}, want: 255},
+		{args: []string{"-v=255", "-v"}, wantErr: true},
+		{args: []string{"-v=300"}, wantErr: true},
+		{args: []string{"-v=-1"}, wantErr: true},
+		{args: []string{"-v=3", "-v=false"}, want: 0},
+	}
+	for _, tt := range tests {
+		var s struct {
+			V uint8 `flag:"v,,verbosity|count"`
+		}
+		err := setFromArgs(t, &s, tt.args)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%q: got V=%d, want an error", tt.args, s.V)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.args, err)
+		} else if s.V != tt.want {
+			t.Errorf("%q: got V=%d, want %d", tt.args, s.V, tt.want)
+		}
+	}
+	var s struct {
+		V int8 `flag:"v,,verbosity|count"`
+	}
+	if err := setFromArgs(t, &s, []string{"-v=-128"}); err != nil || s.V != -128 {
+		t.Errorf("got V=%d, err=%v, want -128", s.V, err)
+	}
+}
+
+func TestNegatedValue(t *testing.T) {
+	var s struct {
+		B bool `flag:"b,true,b|negatable"`
+	}
+	if err := setFromArgs(t, &s, []string{"-no-b"}); err != nil {
+		t.Fatal(err)
+	}
+	if s.B {
+		t.Error("got B=true, want false")
+	}
+}
+
+func TestNegatedWrappedValue(t *testing.T) {
+	var s struct {
+		B bool `flag:"b,true,b|negatable|oneof=true,false"`
+	}
+	if err := setFromArgs(t, &s, []string{"-no-b"}); err != nil {
+		t.Fatal(err)
+	}
+	if s.B {
+		t.Error("got B=true, want false")
+	}
+}
+
+func TestOverflows(t *testing.T) {
+	tests := []struct {
+		v    any
+		typ  any
+		want bool
+	}{
+		{int(255), uint8(0), false},
+		{int(256), uint8(0), true},
+		{int(-1), uint8(0), true},
+		{int64(127), int8(0), false},
+		{int64(128), int8(0), true},
+		{int64(-129), int8(0), true},
+		{uint64(1 << 63), int64(0), true},
+		{uint(65535), uint16(0), false},
+		{uint(65536), uint16(0), true},
+		{float64(1e39), float32(0), true},
+		{float64(1e38), float32(0), false},
+	}
+	for _, tt := range tests {
+		v, typ := reflect.ValueOf(tt.v), reflect.TypeOf(tt.typ)
+		if got := overflows(v, typ); got != tt.want {
+			t.Errorf("overflows(%v, %s) = %v, want %v", tt.v, typ, got, tt.want)
+		}
+	}
+}
+
+func TestUint8Boundaries(t *testing.T) {
+	for _, tt := range []struct {
+		arg     string
+		wantErr bool
+	}{{"255", false}, {"256", true}, {"-1", true}} {
+		var s struct {
+			B uint8 `flag:"b,,b"`
+		}
+		if err := setFromArgs(t, &s, []string{"-b", tt.arg}); (err != nil) != tt.wantErr {
+			t.Errorf("-b %s: got B=%d, err=%v", tt.arg, s.B, err)
+		}
+		// The flag is defined outside of the package, its int value
+		// being converted to the uint8 field
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.Int("b", 0, "b")
+		if err := fs.Parse([]string{"-b", tt.arg}); err != nil {
+			t.Fatal(err)
+		}
+		if err := SetFromFlagsErr(&s, fs); (err != nil) != tt.wantErr {
+			t.Errorf("converted -b %s: got B=%d, err=%v", tt.arg, s.B, err)
+		}
+	}
+}
+
+func TestBigIntWithoutDefault(t *testing.T) {
+	var s struct {
+		N big.Int  `flag:"n,,n"`
+		P *big.Int `flag:"p,,p"`
+	}
+	if err := setFromArgs(t, &s, nil); err != nil {
+		t.Fatal(err)
+	}
+	if s.N.Sign() != 0 || s.P != nil {
+		t.Errorf("got N=%v P=%v, want N=0 P=nil", &s.N, s.P)
+	}
+	if err := setFromArgs(t, &s, []string{"-n", "123456789012345678901234567890", "-p", "-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.N.String(); got != "123456789012345678901234567890" {
+		t.Errorf("got N=%s", got)
+	}
+	if s.P == nil || s.P.Int64() != -1 {
+		t.Errorf("got P=%v, want -1", s.P)
+	}
+}
+
+func TestIntBases(t *testing.T) {
+	var s struct {
+		Mask  int    `flag:"mask,0xff,mask"`
+		Perms uint32 `flag:"perms,0o755,perms"`
+		Bits  int8   `flag:"bits,0b101,bits"`
+		Big   uint64 `flag:"big,0,big"`
+	}
+	if err := setFromArgs(t, &s, nil); err != nil {
+		t.Fatal(err)
+	}
+	if s.Mask != 0xff || s.Perms != 0o755 || s.Bits != 0b101 {
+		t.Errorf("got defaults %d %o %b", s.Mask, s.Perms, s.Bits)
+	}
+	if err := setFromArgs(t, &s, []string{"-mask", "0b11", "-perms", "0x10", "-bits", "-0o7", "-big", "0xffffffffffffffff"}); err != nil {
+		t.Fatal(err)
+	}
+	if s.Mask != 3 || s.Perms != 16 || s.Bits != -7 || s.Big != 1<<64-1 {
+		t.Errorf("got %d %d %d %d", s.Mask, s.Perms, s.Bits, s.Big)
+	}
+	if err := setFromArgs(t, &s, []string{"-bits", "0x80"}); err == nil {
+		t.Error("got no error for an int8 out of range")
+	}
+}
+
+// re is a flag.Value whose String method has a pointer receiver.
+type re struct {
+	re *regexp.Regexp
+}
+
+func (r *re) Set(s string) error {
+	var err error
+	r.re, err = regexp.Compile(s)
+	return err
+}
+
+func (r *re) String() string {
+	if r.re == nil {
+		return ""
+	}
+	return r.re.String()
+}
+
+func TestSliceOfValuesString(t *testing.T) {
+	var s struct {
+		Filters []re          `flag:"filter,^a,filters"`
+		Res     map[string]re `flag:"re,k=^b,regexps"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := AddFlagsWith(fs, &s); err != nil {
+		t.Fatal(err)
+	}
+	if got := fs.Lookup("filter").DefValue; got != "^a" {
+		t.Errorf("got filter default %q, want %q", got, "^a")
+	}
+	if got := fs.Lookup("re").DefValue; got != "k=^b" {
+		t.Errorf("got re default %q, want %q", got, "k=^b")
+	}
+	if err := fs.Parse([]string{"-filter", "x+", "-filter", "y?"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := fs.Lookup("filter").Value.String(); got != "x+,y?" {
+		t.Errorf("got filter %q, want %q", got, "x+,y?")
+	}
+	if err := SetFromFlagsErr(&s, fs); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Filters) != 2 || s.Filters[1].String() != "y?" {
+		t.Errorf("got filters %v", s.Filters)
+	}
+}
+
+type timeout int64
+
+func TestNamedDuration(t *testing.T) {
+	var s struct {
+		Timeout timeout  `flag:"timeout,1m30s,timeout|duration"`
+		Retry   *timeout `flag:"retry,,retry|duration"`
+		Plain   timeout  `flag:"plain,0,plain"`
+	}
+	if err := setFromArgs(t, &s, []string{"-retry", "250ms", "-plain", "42"}); err != nil {
+		t.Fatal(err)
+	}
+	if time.Duration(s.Timeout) != 90*time.Second {
+		t.Errorf("got Timeout=%v, want 1m30s", time.Duration(s.Timeout))
+	}
+	if s.Retry == nil || time.Duration(*s.Retry) != 250*time.Millisecond {
+		t.Errorf("got Retry=%v, want 250ms", s.Retry)
+	}
+	if s.Plain != 42 {
+		t.Errorf("got Plain=%d, want 42", s.Plain)
+	}
+	var bad struct {
+		Timeout int32 `flag:"timeout,,timeout|duration"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := AddFlagsWith(fs, &bad); err == nil {
+		t.Error("got no error for an int32 duration field")
+	}
+}
+
+func TestMapValues(t *testing.T) {
+	var s struct {
+		Weights  map[string]int           `flag:"weight,,weights|sep=,"`
+		Features map[string]bool          `flag:"feature,,features"`
+		Timeouts map[string]time.Duration `flag:"timeout,,timeouts"`
+	}
+	args := []string{"-weight", "a=1,b=2", "-feature", "x=true", "-feature", "y=0", "-timeout", "read=5s"}
+	if err := setFromArgs(t, &s, args); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"a": 1, "b": 2}; !reflect.DeepEqual(s.Weights, want) {
+		t.Errorf("got Weights=%v, want %v", s.Weights, want)
+	}
+	if want := map[string]bool{"x": true, "y": false}; !reflect.DeepEqual(s.Features, want) {
+		t.Errorf("got Features=%v, want %v", s.Features, want)
+	}
+	if want := map[string]time.Duration{"read": 5 * time.Second}; !reflect.DeepEqual(s.Timeouts, want) {
+		t.Errorf("got Timeouts=%v, want %v", s.Timeouts, want)
+	}
+	for _, tt := range []struct {
+		arg string
+		key string
+	}{
+		{"-weight=a=x", "a"},
+		{"-feature=x=maybe", "x"},
+		{"-timeout=read=5", "read"},
+	} {
+		err := setFromArgs(t, &s, []string{tt.arg})
+		if err == nil || !strings.Contains(err.Error(), fmt.Sprintf("key %q", tt.key)) {
+			t.Errorf("%s: got error %v, want an error naming the key %q", tt.arg, err, tt.key)
+		}
+	}
+	if err := setFromArgs(t, &s, []string{"-weight=a"}); err == nil {
+		t.Error("got no error for an entry without value")
+	}
+}
+
+func TestSliceAndMapCopies(t *testing.T) {
+	type config struct {
+		S []string          `flag:"s,a,s"`
+		M map[string]string `flag:"m,k=v,m"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	var a, b config
+	if err := AddFlagsWith(fs, &a); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetFromFlagsErr(&a, fs); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetFromFlagsErr(&b, fs); err != nil {
+		t.Fatal(err)
+	}
+	a.S[0] = "x"
+	a.M["k"] = "x"
+	if b.S[0] != "a" || b.M["k"] != "v" {
+		t.Errorf("got S=%q M=%q after mutating another struct", b.S, b.M)
+	}
+	if got := fs.Lookup("s").Value.String(); got != "a" {
+		t.Errorf("got flag s %q after mutating the field, want a", got)
+	}
+	if got := fs.Lookup("m").Value.String(); got != "k=v" {
+		t.Errorf("got flag m %q after mutating the field, want k=v", got)
+	}
+	if err := fs.Set("s", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if b.S[0] != "a" {
+		t.Errorf("got S=%q after setting the flag again, want [a]", b.S)
+	}
+}
+
+// celsius is a temperature whose flag.Value, registered with
+// RegisterType, returns an unexported type from Get.
+type celsius struct {
+	deg float64
+}
+
+func (c *celsius) Set(s string) error {
+	var err error
+	c.deg, err = strconv.ParseFloat(strings.TrimSuffix(s, "C"), 64)
+	return err
+}
+
+func (c *celsius) String() string {
+	if c == nil {
+		return ""
+	}
+	return strconv.FormatFloat(c.deg, 'g', -1, 64) + "C"
+}
+
+type kelvin struct {
+	deg float64
+}
+
+// celsiusValue parses celsius temperatures but gives them in kelvin.
+type celsiusValue struct {
+	c celsius
+}
+
+func (v *celsiusValue) Set(s string) error { return v.c.Set(s) }
+func (v *celsiusValue) String() string     { return v.c.String() }
+func (v *celsiusValue) Get() any           { return kelvin{v.c.deg + 273.15} }
+
+func TestGetterRoundTrip(t *testing.T) {
+	RegisterType(reflect.TypeOf(celsius{}), func(deflt string, help string) flag.Value {
+		v := &celsiusValue{}
+		if deflt != "" {
+			v.Set(deflt)
+		}
+		return v
+	})
+	defer func() {
+		typesMu.Lock()
+		delete(types, reflect.TypeOf(celsius{}))
+		typesMu.Unlock()
+		clearFieldCache()
+	}()
+	var s struct {
+		Temp celsius `flag:"temp,20C,temperature"`
+	}
+	if err := setFromArgs(t, &s, []string{"-temp", "37.5C"}); err != nil {
+		t.Fatal(err)
+	}
+	if s.Temp.deg != 37.5 {
+		t.Errorf("got Temp=%v, want 37.5C", &s.Temp)
+	}
+}
+
+func TestIntConversion(t *testing.T) {
+	tests := []struct {
+		arg     string
+		wantErr bool
+	}{
+		{arg: "42"},
+		{arg: "-9223372036854775808", wantErr: strconv.IntSize == 32},
+		{arg: "9223372036854775807", wantErr: strconv.IntSize == 32},
+		{arg: "2147483647"},
+		{arg: "-2147483648"},
+	}
+	for _, tt := range tests {
+		var s struct {
+			N int `flag:"n,,n"`
+		}
+		// The flag is an int64 flag defined outside of the package,
+		// its value being converted to the int field
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.Int64("n", 0, "n")
+		if err := fs.Parse([]string{"-n", tt.arg}); err != nil {
+			t.Fatal(err)
+		}
+		err := SetFromFlagsErr(&s, fs)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: got N=%d, want an error", tt.arg, s.N)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.arg, err)
+		} else if got := strconv.Itoa(s.N); got != tt.arg {
+			t.Errorf("%s: got N=%s", tt.arg, got)
+		}
+	}
+	var s struct {
+		N int8 `flag:"n,,n"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Int("n", 0, "n")
+	if err := fs.Parse([]string{"-n", "256"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetFromFlagsErr(&s, fs); err == nil {
+		t.Errorf("got N=%d, want an error", s.N)
+	}
+}
+
+func TestIntFlags(t *testing.T) {
+	var s struct {
+		N int    `flag:"n,,n"`
+		U uint16 `flag:"u,,u"`
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := AddFlagsErr(fs, &s); err != nil {
+		t.Fatal(err)
+	}
+	// The integer flags hold 64-bit values whatever the width of their
+	// field
+	if got := fs.Lookup("n").Value.(flag.Getter).Get(); got != int64(0) {
+		t.Errorf("got value %#v for -n, want int64(0)", got)
+	}
+	if got := fs.Lookup("u").Value.(flag.Getter).Get(); got != uint64(0) {
+		t.Errorf("got value %#v for -u, want uint64(0)", got)
+	}
+	if err := setFromArgs(t, &s, []string{"-n", "-2147483648", "-u", "65535"}); err != nil {
+		t.Fatal(err)
+	}
+	if s.N != -2147483648 || s.U != 65535 {
+		t.Errorf("got N=%d U=%d", s.N, s.U)
+	}
+	if err := setFromArgs(t, &s, []string{"-n", "9223372036854775807"}); (err != nil) != (strconv.IntSize == 32) {
+		t.Errorf("got N=%d, err=%v for a 64-bit value", s.N, err)
+	}
+	if err := setFromArgs(t, &s, []string{"-u", "65536"}); err == nil {
+		t.Errorf("got U=%d, want an error", s.U)
+	}
+	var d struct {
+		N int8 `flag:"n,128,n"`
+	}
+	if err := AddFlagsErr(flag.NewFlagSet("test", flag.ContinueOnError), &d); err == nil {
+		t.Error("got no error for a default out of range")
+	}
+}
+
+// toggle is a boolean-like encoding.TextUnmarshaler usable as a
+// boolean flag.
+type toggle struct {
+	on bool
+}
+
+func (t *toggle) UnmarshalText(b []byte) error {
+	switch string(b) {
+	case "true", "on":
+		t.on = true
+	case "false", "off":
+		t.on = false
+	default:
+		return fmt.Errorf("invalid toggle %q", b)
+	}
+	return nil
+}
+
+func (t *toggle) IsBoolFlag() bool {
+	return true
+}
+
+func TestBoolFlagForms(t *testing.T) {
+	type config struct {
+		V      int    `flag:"v,,verbosity|count"`
+		Color  bool   `flag:"color,true,color|negatable"`
+		Toggle toggle `flag:"toggle,,toggle"`
+	}
+	tests := []struct {
+		args []string
+		want config
+	}{
+		{args: nil, want: config{Color: true}},
+		{args: []string{"-v", "-v", "-no-color", "-toggle"}, want: config{V: 2, Toggle: toggle{true}}},
+		{args: []string{"-v=5", "-no-color=false", "-toggle=off"}, want: config{V: 5, Color: true}},
+		{args: []string{"-v=3", "-v", "-color=false", "-toggle=on"}, want: config{V: 4, Toggle: toggle{true}}},
+		{args: []string{"-v", "-toggle", "x"}, want: config{V: 1, Color: true, Toggle: toggle{true}}},
+	}
+	for _, tt := range tests {
+		var c config
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		if err := AddFlagsWith(fs, &c); err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.Parse(tt.args); err != nil {
+			t.Errorf("%q: %v", tt.args, err)
+			continue
+		}
+		if err := SetFromFlagsErr(&c, fs); err != nil {
+			t.Errorf("%q: %v", tt.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(c, tt.want) {
+			t.Errorf("%q: got %+v, want %+v", tt.args, c, tt.want)
+		}
+	}
+}
+
+func TestNetipValues(t *testing.T) {
+	type config struct {
+		Addr   netip.Addr     `flag:"addr,127.0.0.1,addr"`
+		Prefix netip.Prefix   `flag:"prefix,,prefix"`
+		Listen netip.AddrPort `flag:"listen,,listen"`
+		Peer   *netip.Addr    `flag:"peer,,peer"`
+	}
+	var c config
+	if err := setFromArgs(t, &c, nil); err != nil {
+		t.Fatal(err)
+	}
+	if c.Addr != netip.MustParseAddr("127.0.0.1") || c.Prefix.IsValid() || c.Listen.IsValid() || c.Peer != nil {
+		t.Errorf("got %+v, want the default address only", c)
+	}
+	args := []string{"-addr", "::1", "-prefix", "10.0.0.0/8", "-listen", "[::1]:8080", "-peer", "192.0.2.1"}
+	if err := setFromArgs(t, &c, args); err != nil {
+		t.Fatal(err)
+	}
+	want := config{
+		Addr:   netip.MustParseAddr("::1"),
+		Prefix: netip.MustParsePrefix("10.0.0.0/8"),
+		Listen: netip.MustParseAddrPort("[::1]:8080"),
+	}
+	if c.Peer == nil || *c.Peer != netip.MustParseAddr("192.0.2.1") {
+		t.Errorf("got Peer=%v, want 192.0.2.1", c.Peer)
+	}
+	c.Peer = nil
+	if c != want {
+		t.Errorf("got %+v, want %+v", c, want)
+	}
+	for _, arg := range []string{"-addr=", "-addr=300.0.0.1", "-prefix=", "-prefix=10.0.0.0", "-listen=", "-listen=::1"} {
+		if err := setFromArgs(t, &c, []string{arg}); err == nil {
+			t.Errorf("%s: got no error", arg)
+		}
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	if err := AddFlagsWith(fs, &config{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := fs.Lookup("prefix").DefValue; got != "" {
+		t.Errorf("got prefix default %q, want an empty string", got)
+	}
+}